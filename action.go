@@ -0,0 +1,90 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// Action defines how a discovered attribute is getting applied
+// to a resource which already contains that attribute.
+type Action string
+
+const (
+	// ActionInsert leaves attributes already present untouched
+	ActionInsert Action = "insert"
+	// ActionUpsert overwrites attributes already present
+	// with the locally discovered value
+	ActionUpsert Action = "upsert"
+	// ActionVerify leaves attributes already present untouched, but
+	// flags resources whose value differs from the locally discovered one
+	// by adding the discovered value with the suffix `.discovered`
+	ActionVerify Action = "verify"
+)
+
+// DiscoveredSuffix is appended to the key of an attribute in order to
+// flag a mismatch between its value and the locally discovered one
+const DiscoveredSuffix = ".discovered"
+
+// Validate checks if the action is known
+func (a Action) Validate() error {
+	switch a {
+	case ActionInsert, ActionUpsert, ActionVerify:
+		return nil
+	}
+	return fmt.Errorf("unknown action %q, expected one of %q, %q or %q", a, ActionInsert, ActionUpsert, ActionVerify)
+}
+
+// attributeActions determines the action to use per attribute key
+type attributeActions struct {
+	defaultAction Action
+	overrides     map[string]Action
+}
+
+func (a attributeActions) of(key string) Action {
+	if action, found := a.overrides[key]; found {
+		return action
+	}
+	if a.defaultAction == "" {
+		return ActionInsert
+	}
+	return a.defaultAction
+}
+
+// apply adds the attribute with the given key and value to the given
+// resource attributes, according to the action configured for that key.
+// Returns true if the attribute has been set to the given value.
+func (a attributeActions) apply(attrs pcommon.Map, key string, value string) bool {
+	existing, found := attrs.Get(key)
+	if !found {
+		attrs.PutStr(key, value)
+		return true
+	}
+	switch a.of(key) {
+	case ActionUpsert:
+		attrs.PutStr(key, value)
+		return true
+	case ActionVerify:
+		if existing.AsString() != value {
+			attrs.PutStr(key+DiscoveredSuffix, value)
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/pdatatest/ptracetest"
+)
+
+func TestDynatraceProcessorActions(t *testing.T) {
+	const discoveredHostID = "HOST-2EF98EFF909EE3F6"
+	const forwardedHostID = "HOST-0000000000000000"
+
+	tests := []struct {
+		name             string
+		action           dynatraceprocessor.Action
+		attributeActions map[string]dynatraceprocessor.Action
+		sourceAttributes map[string]string
+		wantAttributes   map[string]string
+	}{
+		{
+			name:             "insert",
+			action:           dynatraceprocessor.ActionInsert,
+			sourceAttributes: map[string]string{dynatraceprocessor.KeyEntityHost: forwardedHostID, "dt.host_group.id": "staging"},
+			wantAttributes:   map[string]string{dynatraceprocessor.KeyEntityHost: forwardedHostID, "dt.host_group.id": "staging"},
+		},
+		{
+			name:             "default_is_insert",
+			sourceAttributes: map[string]string{dynatraceprocessor.KeyEntityHost: forwardedHostID},
+			wantAttributes:   map[string]string{dynatraceprocessor.KeyEntityHost: forwardedHostID, "dt.host_group.id": "production"},
+		},
+		{
+			name:             "upsert",
+			action:           dynatraceprocessor.ActionUpsert,
+			sourceAttributes: map[string]string{dynatraceprocessor.KeyEntityHost: forwardedHostID, "dt.host_group.id": "staging"},
+			wantAttributes:   map[string]string{dynatraceprocessor.KeyEntityHost: discoveredHostID, "dt.host_group.id": "production"},
+		},
+		{
+			name:             "verify_matching",
+			action:           dynatraceprocessor.ActionVerify,
+			sourceAttributes: map[string]string{dynatraceprocessor.KeyEntityHost: discoveredHostID, "dt.host_group.id": "production"},
+			wantAttributes:   map[string]string{dynatraceprocessor.KeyEntityHost: discoveredHostID, "dt.host_group.id": "production"},
+		},
+		{
+			name:             "verify_mismatch",
+			action:           dynatraceprocessor.ActionVerify,
+			sourceAttributes: map[string]string{dynatraceprocessor.KeyEntityHost: forwardedHostID},
+			wantAttributes: map[string]string{
+				dynatraceprocessor.KeyEntityHost:                                       forwardedHostID,
+				dynatraceprocessor.KeyEntityHost + dynatraceprocessor.DiscoveredSuffix: discoveredHostID,
+				"dt.host_group.id": "production",
+			},
+		},
+		{
+			name:             "attribute_action_overrides_default",
+			action:           dynatraceprocessor.ActionVerify,
+			attributeActions: map[string]dynatraceprocessor.Action{"dt.host_group.id": dynatraceprocessor.ActionUpsert},
+			sourceAttributes: map[string]string{dynatraceprocessor.KeyEntityHost: forwardedHostID, "dt.host_group.id": "staging"},
+			wantAttributes: map[string]string{
+				dynatraceprocessor.KeyEntityHost:                                       forwardedHostID,
+				dynatraceprocessor.KeyEntityHost + dynatraceprocessor.DiscoveredSuffix: discoveredHostID,
+				"dt.host_group.id": "production",
+			},
+		},
+	}
+
+	propertiesFile := filepath.Join(t.TempDir(), "dt_metadata.properties")
+	require.NoError(t, os.WriteFile(propertiesFile, []byte("dt.host_group.id=production"), 0o600))
+	ctx := context.WithValue(context.Background(), dynatraceprocessor.MetaDataKeyDTEntityHost, discoveredHostID)
+	ctx = context.WithValue(ctx, dynatraceprocessor.CtxKeyMetaDataPropertiesFilePaths, []string{propertiesFile})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &dynatraceprocessor.Config{
+				Metadata:         true,
+				Action:           tt.action,
+				AttributeActions: tt.attributeActions,
+				Properties:       dynatraceprocessor.PropertiesConfig{Enabled: true},
+			}
+			require.NoError(t, cfg.Validate())
+
+			sink := new(consumertest.TracesSink)
+			tp, err := dynatraceprocessor.NewFactory().CreateTraces(ctx, processortest.NewNopSettings(), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, tp.ConsumeTraces(ctx, generateTraceData(tt.sourceAttributes)))
+			require.Len(t, sink.AllTraces(), 1)
+			assert.NoError(t, ptracetest.CompareTraces(generateTraceData(tt.wantAttributes), sink.AllTraces()[0]))
+		})
+	}
+}
@@ -18,17 +18,387 @@
 package dynatraceprocessor
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"go.opentelemetry.io/collector/component"
 )
 
 // Config defines configuration for Resource processor.
 type Config struct {
 	Metadata bool `mapstructure:"metadata"`
+	// RequireHostID defines whether creating the processor fails
+	// if no host ID could be discovered
+	RequireHostID bool `mapstructure:"require_host_id"`
+	// OnMissingHostID defines what happens to batches containing resources
+	// without `dt.entity.host` after the enrichment: `pass` (default),
+	// `reject` or `drop`
+	OnMissingHostID MissingHostIDAction `mapstructure:"on_missing_host_id"`
+	// MetaDataFiles lists the `dt_metadata.properties` and `dt_metadata.json`
+	// files to look for the host ID and the metadata, in that order.
+	// Files containing a JSON object are getting parsed as JSON.
+	MetaDataFiles []string `mapstructure:"metadata_files"`
+	// RuxitHostIDFiles lists the `ruxithost.id` files to look for the
+	// host ID, in that order, in case none of the MetaDataFiles contain it.
+	RuxitHostIDFiles []string `mapstructure:"ruxit_host_id_files"`
+	// Sources lists the names of the host ID sources to ask for the
+	// host ID, in that order. Built-in sources are `metadata_file` and
+	// `ruxithost_id`. If empty, all sources are getting asked,
+	// ordered by their priority.
+	Sources []string `mapstructure:"sources"`
+	// Action defines how discovered attributes are getting applied to
+	// resources already containing them. Defaults to `insert`.
+	Action Action `mapstructure:"action"`
+	// AttributeActions overrides Action for individual attribute keys
+	AttributeActions map[string]Action `mapstructure:"attribute_actions"`
+	// Properties configures the enrichment with all the keys found
+	// in the `dt_metadata.properties` file provided by OneAgent
+	Properties PropertiesConfig `mapstructure:"properties"`
+	// Env configures the enrichment with attributes
+	// derived from environment variables
+	Env EnvConfig `mapstructure:"env"`
+	// Kubernetes configures the enrichment with attributes
+	// derived from files mounted via the Kubernetes downward API
+	Kubernetes KubernetesConfig `mapstructure:"kubernetes"`
+	// HostIdentity configures the attributes identifying the current
+	// host in case no host ID could be discovered
+	HostIdentity HostIdentityConfig `mapstructure:"host_identity"`
+	// ResourceAttributes lists static attributes to add to every resource,
+	// e.g. `dt.security_context` or `dt.cost.costcenter`. They take
+	// precedence over attributes found in the enrichment files, a
+	// configured `dt.entity.host` over the discovered host ID.
+	ResourceAttributes map[string]string `mapstructure:"resource_attributes"`
+	// NormalizeMetrics configures the rewriting of metrics violating
+	// the Dynatrace metric ingestion rules
+	NormalizeMetrics NormalizeMetricsConfig `mapstructure:"normalize_metrics"`
+	// MetricCompat configures the removal or conversion
+	// of metrics Dynatrace can't ingest
+	MetricCompat MetricCompatConfig `mapstructure:"metric_compat"`
+	// CumulativeToDelta configures the conversion of cumulative sums
+	// and histograms into the delta temporality expected by Dynatrace
+	CumulativeToDelta CumulativeToDeltaConfig `mapstructure:"cumulative_to_delta"`
+	// CardinalityLimit configures the limit of distinct
+	// attribute sets per metric
+	CardinalityLimit CardinalityLimitConfig `mapstructure:"cardinality_limit"`
+	// SpanAttributes configures the span attribute pass
+	// supporting Dynatrace service detection
+	SpanAttributes SpanAttributesConfig `mapstructure:"span_attributes"`
+	// Severity configures the inference of the severity of
+	// log records which don't specify one
+	Severity SeverityConfig `mapstructure:"severity"`
+	// TraceCorrelation configures the attributes
+	// linking log records to traces
+	TraceCorrelation TraceCorrelationConfig `mapstructure:"trace_correlation"`
+	// LogLimits configures the truncation of log records
+	// exceeding the Dynatrace log ingestion limits
+	LogLimits LogLimitsConfig `mapstructure:"log_limits"`
+	// RefreshInterval defines how often the host ID and the enrichment
+	// files are getting re-evaluated. Zero disables re-evaluation.
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	// Watch configures the re-evaluation of the host ID and the
+	// enrichment files whenever one of these files changes
+	Watch WatchConfig `mapstructure:"watch"`
+}
+
+// EnvConfig defines which environment variables are getting
+// added as resource attributes.
+type EnvConfig struct {
+	// Enabled defines whether environment variables are getting evaluated
+	Enabled bool `mapstructure:"enabled"`
+	// Prefix defines which environment variables are getting evaluated.
+	// Variables which may contain credentials, e.g. `DT_API_TOKEN`,
+	// are never getting evaluated. Defaults to `DT_`.
+	Prefix string `mapstructure:"prefix"`
+	// Mapping overrides the resource attribute key
+	// derived from the name of an environment variable
+	Mapping map[string]string `mapstructure:"mapping"`
+	// Precedence defines whether the enrichment files (`files`) or the
+	// environment variables (`env`) win if both provide the same attribute.
+	// Defaults to `files`.
+	Precedence Precedence `mapstructure:"precedence"`
+}
+
+// KubernetesConfig defines which files mounted into the pod
+// are getting added as resource attributes.
+type KubernetesConfig struct {
+	// Enabled defines whether the files are getting evaluated
+	Enabled bool `mapstructure:"enabled"`
+	// Files maps resource attribute keys to the files containing their
+	// values, e.g. `k8s.cluster.uid` or `dt.kubernetes.workload.name`.
+	// An empty file path disables the attribute.
+	Files map[string]string `mapstructure:"files"`
+	// NamespaceFile defines the file containing the namespace of the
+	// service account, used if `k8s.namespace.name` isn't available
+	// via Files. Empty disables the fallback.
+	NamespaceFile string `mapstructure:"namespace_file"`
+}
+
+// HostIdentityConfig defines which sources are getting used to identify
+// the current host in case no host ID could be discovered, e.g. because
+// OneAgent isn't installed.
+type HostIdentityConfig struct {
+	// MachineID defines whether `host.id` is getting read from `/etc/machine-id`
+	MachineID bool `mapstructure:"machine_id"`
+	// DMIProductUUID defines whether `host.id` is getting read from
+	// `/sys/class/dmi/id/product_uuid`, if the machine ID is unavailable
+	DMIProductUUID bool `mapstructure:"dmi_product_uuid"`
+	// Hostname defines whether `host.name` is getting added
+	Hostname bool `mapstructure:"hostname"`
+	// OSType defines whether `os.type` is getting added
+	OSType bool `mapstructure:"os_type"`
+}
+
+// NormalizeMetricsConfig defines whether metric names and data point
+// attributes are getting rewritten to comply with the Dynatrace metric
+// ingestion rules.
+type NormalizeMetricsConfig struct {
+	// Enabled defines whether metrics are getting normalized
+	Enabled bool `mapstructure:"enabled"`
+	// OriginalNameAttribute defines the data point attribute the original
+	// name of a rewritten metric is getting recorded in.
+	// Empty disables recording the original name.
+	OriginalNameAttribute string `mapstructure:"original_name_attribute"`
+}
+
+// MetricCompatConfig defines whether and how metrics Dynatrace
+// can't ingest are getting removed or converted.
+type MetricCompatConfig struct {
+	// Enabled defines whether incompatible metrics are getting
+	// removed or converted
+	Enabled bool `mapstructure:"enabled"`
+	// Summary defines whether summaries are getting converted (`convert`)
+	// or removed (`drop`). Defaults to `convert`.
+	Summary SummaryMode `mapstructure:"summary"`
+}
+
+// CumulativeToDeltaConfig defines whether cumulative sums, histograms and
+// exponential histograms are getting converted into deltas.
+type CumulativeToDeltaConfig struct {
+	// Enabled defines whether cumulative data points are getting converted
+	Enabled bool `mapstructure:"enabled"`
+	// MaxStreams limits the number of streams the previous data point is
+	// getting kept for. Data points of further streams are getting dropped.
+	// Zero means no limit.
+	MaxStreams int `mapstructure:"max_streams"`
+	// MaxStaleness defines after how long without data points a stream
+	// is getting forgotten. Zero means streams are never forgotten.
+	MaxStaleness time.Duration `mapstructure:"max_staleness"`
+}
+
+// CardinalityLimitConfig defines how many distinct attribute sets
+// (series) per metric name are getting passed on within a rolling window.
+type CardinalityLimitConfig struct {
+	// Enabled defines whether the number of series is getting limited
+	Enabled bool `mapstructure:"enabled"`
+	// MaxSeriesPerMetric defines the number of series per metric name
+	// passed on unchanged. Defaults to 1000.
+	MaxSeriesPerMetric int `mapstructure:"max_series_per_metric"`
+	// Window defines after how long without data points a series no
+	// longer counts against the limit. Defaults to 1h.
+	Window time.Duration `mapstructure:"window"`
+	// Action defines whether data points of further series are getting
+	// dropped (`drop`) or their attribute values are getting replaced with
+	// `dt.overflow` and merged into a single data point per metric
+	// (`overflow`). Defaults to `overflow`.
+	Action CardinalityAction `mapstructure:"action"`
+}
+
+// SpanAttributesConfig defines how the attributes of spans are getting
+// aligned with the semantic conventions Dynatrace service detection relies on.
+type SpanAttributesConfig struct {
+	// Enabled defines whether deprecated span attributes
+	// are getting mapped to their current names
+	Enabled bool `mapstructure:"enabled"`
+	// KeepDeprecated defines whether the deprecated attributes
+	// are getting kept alongside the current ones
+	KeepDeprecated bool `mapstructure:"keep_deprecated"`
+	// DefaultServiceName is getting used as `service.name` for
+	// resources without one. Empty disables the default.
+	DefaultServiceName string `mapstructure:"default_service_name"`
+}
+
+// SeverityConfig defines how the severity of log records
+// without a severity number is getting inferred.
+type SeverityConfig struct {
+	// Enabled defines whether the severity is getting inferred
+	Enabled bool `mapstructure:"enabled"`
+	// Attributes lists the attributes to look for the level, in that order.
+	// Defaults to `level`, `severity`, `log.level`, `loglevel`,
+	// `syslog.severity` and `syslog.priority`.
+	Attributes []string `mapstructure:"attributes"`
+	// BodyPatterns lists the regular expressions to match the body against
+	// in case none of the attributes contains a level, in that order.
+	// The first capture group is expected to contain the level.
+	BodyPatterns []string `mapstructure:"body_patterns"`
+}
+
+// TraceCorrelationConfig defines whether the trace context of log records
+// is getting aligned with the attributes `dt.trace_id`, `dt.span_id`
+// and `dt.trace_sampled`.
+type TraceCorrelationConfig struct {
+	// Enabled defines whether the trace context is getting aligned
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// LogLimitsConfig defines the limits log records are getting truncated to.
+// Zero disables the respective limit.
+type LogLimitsConfig struct {
+	// Enabled defines whether log records are getting truncated
+	Enabled bool `mapstructure:"enabled"`
+	// MaxContentLength defines the maximum length of the body
+	// in bytes. Defaults to 65536.
+	MaxContentLength int `mapstructure:"max_content_length"`
+	// MaxAttributes defines the maximum number of attributes,
+	// including `dt.truncated`. Further attributes are getting removed,
+	// the ones set by the processor itself last. Defaults to 50.
+	MaxAttributes int `mapstructure:"max_attributes"`
+	// MaxAttributeValueLength defines the maximum length of string
+	// attribute values in bytes. Defaults to 250.
+	MaxAttributeValueLength int `mapstructure:"max_attribute_value_length"`
+}
+
+// WatchConfig defines whether and how the enrichment files are getting
+// watched for changes.
+type WatchConfig struct {
+	// Enabled defines whether the enrichment files are getting watched
+	Enabled bool `mapstructure:"enabled"`
+	// Debounce defines how long to wait for further changes
+	// before the files are getting re-evaluated
+	Debounce time.Duration `mapstructure:"debounce"`
+}
+
+// PropertiesConfig defines which keys of the `dt_metadata.properties`
+// file are getting added as resource attributes.
+type PropertiesConfig struct {
+	// Enabled defines whether the keys are getting added at all
+	Enabled bool `mapstructure:"enabled"`
+	// Include lists the keys to add. If empty, every key is getting added.
+	Include []string `mapstructure:"include"`
+	// Exclude lists the keys to never add. Takes precedence over Include.
+	Exclude []string `mapstructure:"exclude"`
 }
 
 var _ component.Config = (*Config)(nil)
 
 // Validate checks if the processor configuration is valid
 func (cfg *Config) Validate() error {
+	if cfg.RequireHostID && !cfg.Metadata {
+		return errors.New("require_host_id requires metadata to be enabled")
+	}
+	if cfg.OnMissingHostID != "" {
+		if err := cfg.OnMissingHostID.Validate(); err != nil {
+			return fmt.Errorf("on_missing_host_id: %w", err)
+		}
+	}
+	for _, filePath := range cfg.MetaDataFiles {
+		if strings.TrimSpace(filePath) == "" {
+			return errors.New("metadata_files must not contain empty file paths")
+		}
+	}
+	for _, filePath := range cfg.RuxitHostIDFiles {
+		if strings.TrimSpace(filePath) == "" {
+			return errors.New("ruxit_host_id_files must not contain empty file paths")
+		}
+	}
+	seen := map[string]bool{}
+	for _, name := range cfg.Sources {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("sources must not contain empty names")
+		}
+		if seen[name] {
+			return fmt.Errorf("sources must not contain %q more than once", name)
+		}
+		seen[name] = true
+	}
+	if cfg.RefreshInterval < 0 {
+		return errors.New("refresh_interval must not be negative")
+	}
+	if cfg.Watch.Debounce < 0 {
+		return errors.New("watch::debounce must not be negative")
+	}
+	if cfg.MetricCompat.Summary != "" {
+		if err := cfg.MetricCompat.Summary.Validate(); err != nil {
+			return fmt.Errorf("metric_compat::summary: %w", err)
+		}
+	}
+	if cfg.CumulativeToDelta.MaxStreams < 0 {
+		return errors.New("cumulative_to_delta::max_streams must not be negative")
+	}
+	if cfg.CumulativeToDelta.MaxStaleness < 0 {
+		return errors.New("cumulative_to_delta::max_staleness must not be negative")
+	}
+	if cfg.CardinalityLimit.Enabled {
+		if cfg.CardinalityLimit.MaxSeriesPerMetric <= 0 {
+			return errors.New("cardinality_limit::max_series_per_metric must be positive")
+		}
+		if cfg.CardinalityLimit.Window <= 0 {
+			return errors.New("cardinality_limit::window must be positive")
+		}
+		if err := cfg.CardinalityLimit.Action.Validate(); err != nil {
+			return fmt.Errorf("cardinality_limit::action: %w", err)
+		}
+	}
+	for _, key := range cfg.Severity.Attributes {
+		if key == "" {
+			return errors.New("severity::attributes must not contain empty keys")
+		}
+	}
+	for _, pattern := range cfg.Severity.BodyPatterns {
+		if _, err := compileSeverityPattern(pattern); err != nil {
+			return fmt.Errorf("severity::body_patterns: %w", err)
+		}
+	}
+	if cfg.LogLimits.MaxContentLength < 0 {
+		return errors.New("log_limits::max_content_length must not be negative")
+	}
+	if cfg.LogLimits.MaxAttributes < 0 {
+		return errors.New("log_limits::max_attributes must not be negative")
+	}
+	if cfg.LogLimits.MaxAttributeValueLength < 0 {
+		return errors.New("log_limits::max_attribute_value_length must not be negative")
+	}
+	if cfg.Action != "" {
+		if err := cfg.Action.Validate(); err != nil {
+			return fmt.Errorf("action: %w", err)
+		}
+	}
+	for key, action := range cfg.AttributeActions {
+		if err := action.Validate(); err != nil {
+			return fmt.Errorf("attribute_actions::%s: %w", key, err)
+		}
+	}
+	if cfg.Env.Enabled && cfg.Env.Prefix == "" {
+		return errors.New("env::prefix must not be empty")
+	}
+	switch cfg.Env.Precedence {
+	case "", PrecedenceFiles, PrecedenceEnv:
+	default:
+		return fmt.Errorf("env::precedence: unknown precedence %q, expected one of %q or %q", cfg.Env.Precedence, PrecedenceFiles, PrecedenceEnv)
+	}
+	for key := range cfg.Kubernetes.Files {
+		if key == "" {
+			return errors.New("kubernetes::files must not contain empty keys")
+		}
+	}
+	for key := range cfg.ResourceAttributes {
+		if key == "" {
+			return errors.New("resource_attributes must not contain empty keys")
+		}
+	}
+	if hostID, found := cfg.ResourceAttributes[KeyEntityHost]; found && !reHostID.MatchString(hostID) {
+		return fmt.Errorf("resource_attributes::%s: invalid host ID %q, expected HOST-<hexadecimal number>", KeyEntityHost, hostID)
+	}
+	for _, key := range cfg.Properties.Include {
+		if key == "" {
+			return errors.New("properties::include must not contain empty keys")
+		}
+	}
+	for _, key := range cfg.Properties.Exclude {
+		if key == "" {
+			return errors.New("properties::exclude must not contain empty keys")
+		}
+	}
 	return nil
 }
@@ -20,6 +20,7 @@ package dynatraceprocessor
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -36,9 +37,302 @@ func TestLoadConfig(t *testing.T) {
 		valid    bool
 	}{
 		{
-			id:       component.NewIDWithName(component.MustNewType("dynatrace"), ""),
-			expected: &Config{Metadata: true},
-			valid:    true,
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), ""),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "properties"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.Properties = PropertiesConfig{
+					Enabled: true,
+					Exclude: []string{"dt.smartscape.process"},
+				}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "properties_empty_key"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Properties = PropertiesConfig{
+					Enabled: true,
+					Include: []string{""},
+				}
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "refresh"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.RefreshInterval = 5 * time.Minute
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "negative_refresh"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.RefreshInterval = -time.Second
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "watch"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.Watch = WatchConfig{Enabled: true, Debounce: 200 * time.Millisecond}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "negative_debounce"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.Watch = WatchConfig{Enabled: true, Debounce: -time.Second}
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "files"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.MetaDataFiles = []string{"/opt/dynatrace/enrichment/dt_metadata.json"}
+				cfg.RuxitHostIDFiles = []string{"/opt/dynatrace/oneagent/agent/config/ruxithost.id"}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "empty_file_path"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.RuxitHostIDFiles = []string{""}
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "actions"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.Action = ActionVerify
+				cfg.AttributeActions = map[string]Action{"dt.host_group.id": ActionUpsert}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "unknown_action"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.AttributeActions = map[string]Action{"dt.host_group.id": "delete"}
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "resource_attributes"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.ResourceAttributes = map[string]string{
+					"dt.security_context": "team-a",
+					"dt.cost.costcenter":  "4711",
+					"dt.cost.product":     "webshop",
+				}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "invalid_resource_attributes_host_id"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.ResourceAttributes = map[string]string{KeyEntityHost: "my-host"}
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "env"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.Env = EnvConfig{
+					Enabled:    true,
+					Prefix:     "DT_",
+					Mapping:    map[string]string{"DT_TAGS": "dt.tags"},
+					Precedence: PrecedenceEnv,
+				}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "unknown_precedence"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Env.Enabled = true
+				cfg.Env.Precedence = "static"
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "kubernetes"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Kubernetes.Enabled = true
+				cfg.Kubernetes.Files[KeyK8sClusterUID] = "/etc/cluster/uid"
+				cfg.Kubernetes.Files[KeyDTKubernetesWorkloadKind] = ""
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "host_identity"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.HostIdentity = HostIdentityConfig{MachineID: true, Hostname: true, OSType: true}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "normalize_metrics"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.NormalizeMetrics = NormalizeMetricsConfig{Enabled: true, OriginalNameAttribute: "otel.metric.name"}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "cumulative_to_delta"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.CumulativeToDelta = CumulativeToDeltaConfig{Enabled: true, MaxStreams: 1000, MaxStaleness: 10 * time.Minute}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "negative_max_streams"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.CumulativeToDelta.Enabled = true
+				cfg.CumulativeToDelta.MaxStreams = -1
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "metric_compat"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.MetricCompat = MetricCompatConfig{Enabled: true, Summary: SummaryModeDrop}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "unknown_summary_mode"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.MetricCompat = MetricCompatConfig{Enabled: true, Summary: "histogram"}
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "cardinality_limit"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.CardinalityLimit = CardinalityLimitConfig{
+					Enabled:            true,
+					MaxSeriesPerMetric: 500,
+					Window:             30 * time.Minute,
+					Action:             CardinalityActionDrop,
+				}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "zero_max_series"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.CardinalityLimit.Enabled = true
+				cfg.CardinalityLimit.MaxSeriesPerMetric = 0
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "log_limits"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.LogLimits.Enabled = true
+				cfg.LogLimits.MaxContentLength = 1024
+				cfg.LogLimits.MaxAttributes = 0
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "negative_max_attributes"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.LogLimits.Enabled = true
+				cfg.LogLimits.MaxAttributes = -1
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "severity"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Severity = SeverityConfig{
+					Enabled:      true,
+					Attributes:   []string{"lvl"},
+					BodyPatterns: []string{`^\[(\w+)\]`},
+				}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "severity_without_capture_group"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Severity.Enabled = true
+				cfg.Severity.BodyPatterns = []string{`^\w+`}
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "trace_correlation"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.TraceCorrelation.Enabled = true
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "span_attributes"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.SpanAttributes = SpanAttributesConfig{Enabled: true, KeepDeprecated: true, DefaultServiceName: "checkout"}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "require_host_id"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.RequireHostID = true
+				cfg.OnMissingHostID = MissingHostIDReject
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "require_host_id_without_metadata"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.RequireHostID = true
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "unknown_missing_host_id_action"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.OnMissingHostID = "ignore"
+			}),
+			valid: false,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "sources"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.Sources = []string{"cmdb", HostIDSourceRuxitHostID}
+			}),
+			valid: true,
+		},
+		{
+			id: component.NewIDWithName(component.MustNewType("dynatrace"), "duplicate_sources"),
+			expected: defaultConfigWith(func(cfg *Config) {
+				cfg.Metadata = true
+				cfg.Sources = []string{HostIDSourceRuxitHostID, HostIDSourceRuxitHostID}
+			}),
+			valid: false,
 		},
 	}
 
@@ -63,3 +357,9 @@ func TestLoadConfig(t *testing.T) {
 		})
 	}
 }
+
+func defaultConfigWith(modify func(cfg *Config)) *Config {
+	cfg := createDefaultConfig().(*Config)
+	modify(cfg)
+	return cfg
+}
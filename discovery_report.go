@@ -0,0 +1,169 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"go.uber.org/zap"
+)
+
+// candidateStatus describes the outcome of evaluating a single file
+type candidateStatus string
+
+const (
+	candidateMissing    candidateStatus = "missing"
+	candidateUnreadable candidateStatus = "unreadable"
+	candidateEmpty      candidateStatus = "empty"
+	candidateInvalid    candidateStatus = "invalid"
+	candidateSelected   candidateStatus = "selected"
+)
+
+// discoveryCandidate describes the evaluation of a single
+// file possibly containing the host ID
+type discoveryCandidate struct {
+	// path is the file evaluated, empty for sources not reading files
+	path   string
+	source string
+	status candidateStatus
+	value  string
+	err    error
+	// attributes holds further attributes found along with the value
+	attributes Metadata
+}
+
+// discoveryReport describes how the host ID has been discovered
+type discoveryReport struct {
+	// candidates lists the files evaluated, in the order they have been looked at
+	candidates []discoveryCandidate
+	hostID     string
+	source     string
+	// attributes holds further attributes provided by the selected source
+	attributes Metadata
+	// decision explains the outcome in a human readable way
+	decision string
+}
+
+// candidateStatusOf determines the status of a candidate
+// based on the value found and the error occurred, if any
+func candidateStatusOf(value string, err error) candidateStatus {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return candidateMissing
+	case err != nil:
+		return candidateUnreadable
+	case len(value) == 0:
+		return candidateEmpty
+	case !reHostID.MatchString(value):
+		return candidateInvalid
+	}
+	return candidateSelected
+}
+
+// location describes where the value of the candidate has been looked for
+func (candidate discoveryCandidate) location() string {
+	if len(candidate.path) > 0 {
+		return candidate.path
+	}
+	return fmt.Sprintf("source %s", candidate.source)
+}
+
+// discoverHostID evaluates the files in order to determine the HostID
+// and reports every file looked at
+func (files enrichmentFiles) discoverHostID() discoveryReport {
+	return discoverHostID(context.Background(), files.hostIDSources())
+}
+
+// discoverHostID asks the given sources for the HostID, in that order, and
+// reports every candidate evaluated. The first candidate containing a value
+// is getting selected. If that value doesn't match the format of a valid
+// Host ID as expected by Dynatrace, no HostID is getting returned.
+func discoverHostID(ctx context.Context, sources []HostIDSource) discoveryReport {
+	report := discoveryReport{source: hostIDSourceNone}
+	for _, source := range sources {
+		var candidates []discoveryCandidate
+		if cs, ok := source.(candidateSource); ok {
+			candidates = cs.evalCandidates()
+		} else {
+			candidates = []discoveryCandidate{evalHostIDSource(ctx, source)}
+		}
+		for _, candidate := range candidates {
+			report.candidates = append(report.candidates, candidate)
+			switch candidate.status {
+			case candidateSelected:
+				report.hostID, report.source = candidate.value, candidate.source
+				report.attributes = candidate.attributes
+				report.decision = fmt.Sprintf("host ID found in %s", candidate.location())
+				return report
+			case candidateInvalid:
+				report.decision = fmt.Sprintf("value %q found in %s doesn't match the host ID format", candidate.value, candidate.location())
+				return report
+			}
+		}
+	}
+	report.decision = "none of the sources provides a host ID"
+	return report
+}
+
+// evalHostIDSource asks a source not reporting candidates on its own for
+// the HostID. The attributes found along with the HostID are getting kept.
+func evalHostIDSource(ctx context.Context, source HostIDSource) discoveryCandidate {
+	candidate := discoveryCandidate{source: source.Name()}
+	attributes, err := source.Discover(ctx)
+	candidate.value, candidate.err = attributes[KeyEntityHost], err
+	candidate.status = candidateStatusOf(candidate.value, candidate.err)
+	for key, value := range attributes {
+		if key == KeyEntityHost {
+			continue
+		}
+		if candidate.attributes == nil {
+			candidate.attributes = Metadata{}
+		}
+		candidate.attributes[key] = value
+	}
+	return candidate
+}
+
+// log writes the decision at info level and
+// every candidate evaluated at debug level
+func (report discoveryReport) log(logger *zap.Logger) {
+	for _, candidate := range report.candidates {
+		fields := []zap.Field{
+			zap.String("source", candidate.source),
+			zap.String("status", string(candidate.status)),
+		}
+		if len(candidate.path) > 0 {
+			fields = append(fields, zap.String("path", candidate.path))
+		}
+		if len(candidate.value) > 0 {
+			fields = append(fields, zap.String("value", candidate.value))
+		}
+		if candidate.err != nil {
+			fields = append(fields, zap.Error(candidate.err))
+		}
+		logger.Debug("Dynatrace host ID candidate evaluated", fields...)
+	}
+	logger.Info("Dynatrace host ID discovery finished",
+		zap.String("host_id", report.hostID),
+		zap.String("source", report.source),
+		zap.String("decision", report.decision),
+		zap.Int("candidates", len(report.candidates)))
+}
@@ -0,0 +1,180 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func writeTestFile(t *testing.T, dir string, name string, content string) string {
+	filePath := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0o600))
+	return filePath
+}
+
+func TestDiscoverHostID(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.properties")
+	unreadable := dir
+	empty := writeTestFile(t, dir, "empty.properties", "dt.host_group.id=webshop")
+	selected := writeTestFile(t, dir, "ruxithost.id", "0123456789ABCDEF")
+
+	report := enrichmentFiles{
+		metaData:    []string{missing, unreadable, empty},
+		ruxitHostID: []string{selected},
+	}.discoverHostID()
+
+	assert.Equal(t, "HOST-0123456789ABCDEF", report.hostID)
+	assert.Equal(t, HostIDSourceRuxitHostID, report.source)
+	assert.Contains(t, report.decision, selected)
+	require.Len(t, report.candidates, 4)
+
+	statuses := []candidateStatus{candidateMissing, candidateUnreadable, candidateEmpty, candidateSelected}
+	for i, candidate := range report.candidates {
+		assert.Equal(t, statuses[i], candidate.status, candidate.path)
+	}
+	assert.Error(t, report.candidates[0].err)
+	assert.Error(t, report.candidates[1].err)
+	assert.Equal(t, HostIDSourceMetadataFile, report.candidates[2].source)
+}
+
+func TestDiscoverHostIDInvalid(t *testing.T) {
+	dir := t.TempDir()
+	invalid := writeTestFile(t, dir, "dt_metadata.properties", "dt.entity.host=HOST-XYZ")
+	valid := writeTestFile(t, dir, "ruxithost.id", "0123456789ABCDEF")
+
+	report := enrichmentFiles{metaData: []string{invalid}, ruxitHostID: []string{valid}}.discoverHostID()
+
+	assert.Empty(t, report.hostID)
+	assert.Equal(t, hostIDSourceNone, report.source)
+	require.Len(t, report.candidates, 1, "the first value found is expected to decide")
+	assert.Equal(t, candidateInvalid, report.candidates[0].status)
+	assert.Equal(t, "HOST-XYZ", report.candidates[0].value)
+	assert.Contains(t, report.decision, "doesn't match")
+}
+
+func TestDiscoverHostIDNone(t *testing.T) {
+	report := enrichmentFiles{}.discoverHostID()
+	assert.Empty(t, report.hostID)
+	assert.Equal(t, hostIDSourceNone, report.source)
+	assert.Empty(t, report.candidates)
+}
+
+func TestDiscoveryReportLogged(t *testing.T) {
+	dir := t.TempDir()
+	cfg := createDefaultConfig().(*Config)
+	cfg.Metadata = true
+	cfg.MetaDataFiles = []string{filepath.Join(dir, "missing.properties")}
+	cfg.RuxitHostIDFiles = []string{writeTestFile(t, dir, "ruxithost.id", "0123456789ABCDEF")}
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	set, _ := newTestTelemetry()
+	set.Logger = zap.New(core)
+	_, err := newDynatraceProcessor(context.Background(), set, cfg, nil)
+	require.NoError(t, err)
+
+	candidates := logs.FilterMessage("Dynatrace host ID candidate evaluated").All()
+	require.Len(t, candidates, 2)
+	assert.Equal(t, zapcore.DebugLevel, candidates[0].Level)
+	assert.Equal(t, string(candidateMissing), candidates[0].ContextMap()["status"])
+	assert.Equal(t, string(candidateSelected), candidates[1].ContextMap()["status"])
+
+	decisions := logs.FilterMessage("Dynatrace host ID discovery finished").All()
+	require.Len(t, decisions, 1)
+	assert.Equal(t, zapcore.InfoLevel, decisions[0].Level)
+	assert.Equal(t, "HOST-0123456789ABCDEF", decisions[0].ContextMap()["host_id"])
+	assert.Equal(t, HostIDSourceRuxitHostID, decisions[0].ContextMap()["source"])
+}
+
+func TestDiscoveryReportNotLoggedWithoutMetadata(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	set, _ := newTestTelemetry()
+	set.Logger = zap.New(core)
+	proc, err := newDynatraceProcessor(context.Background(), set, createDefaultConfig().(*Config), &factory{fsys: fstest.MapFS{}})
+	require.NoError(t, err)
+	assert.Zero(t, logs.FilterMessage("Dynatrace host ID discovery finished").Len())
+	require.NoError(t, proc.shutdown(context.Background()))
+}
+
+func TestFileHostIDSourceDiscover(t *testing.T) {
+	tests := []struct {
+		name           string
+		files          fstest.MapFS
+		wantAttributes map[string]string
+		wantErr        bool
+	}{
+		{
+			name:           "missing",
+			files:          fstest.MapFS{},
+			wantAttributes: map[string]string{},
+		},
+		{
+			name: "empty",
+			files: fstest.MapFS{
+				"var/lib/dynatrace/enrichment/dt_metadata.properties":  {Data: []byte("dt.host_group.id=webshop")},
+				"var/lib/dynatrace/oneagent/agent/config/ruxithost.id": {Data: []byte{}},
+			},
+			wantAttributes: map[string]string{},
+		},
+		{
+			name: "unreadable",
+			files: fstest.MapFS{
+				"var/lib/dynatrace/enrichment/dt_metadata.properties/nested":  {Data: []byte{}},
+				"var/lib/dynatrace/oneagent/agent/config/ruxithost.id/nested": {Data: []byte{}},
+			},
+			wantAttributes: map[string]string{},
+			wantErr:        true,
+		},
+		{
+			name: "found",
+			files: fstest.MapFS{
+				"var/lib/dynatrace/enrichment/dt_metadata.properties":  {Data: []byte("dt.entity.host=HOST-0123456789ABCDEF")},
+				"var/lib/dynatrace/oneagent/agent/config/ruxithost.id": {Data: []byte("0123456789ABCDEF")},
+			},
+			wantAttributes: map[string]string{KeyEntityHost: "HOST-0123456789ABCDEF"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := enrichmentFiles{
+				metaData:    DefaultMetaDataFilePaths(),
+				ruxitHostID: DefaultRuxitHostIDFilePaths(),
+				fsys:        tt.files,
+			}
+			for _, source := range files.hostIDSources() {
+				attributes, err := source.Discover(context.Background())
+				assert.Equal(t, tt.wantAttributes, attributes, source.Name())
+				if tt.wantErr {
+					assert.Error(t, err, source.Name())
+				} else {
+					assert.NoError(t, err, source.Name())
+				}
+			}
+		})
+	}
+}
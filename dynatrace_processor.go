@@ -19,59 +19,319 @@ package dynatraceprocessor
 
 import (
 	"context"
+	"errors"
+	"maps"
+	"os"
+	"sync"
 
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor"
 	"go.uber.org/zap"
 )
 
 type dynatraceProcessor struct {
-	logger *zap.Logger
+	logger          *zap.Logger
+	onMissingHostID MissingHostIDAction
+	actions         attributeActions
+	telemetry       *processorTelemetry
+	metrics         metricsConfig
+	delta           *deltaConverter
+	cardinality     *cardinalityLimiter
+	spanAttributes  *spanAttributes
+	severity        *severityInference
+	correlateTraces bool
+	logLimits       *logLimits
+	// engine discovers the attributes to add,
+	// shared with the other processors of the same config
+	engine   *enrichmentEngine
+	released sync.Once
+}
+
+// enrichment holds the attributes discovered on the current host
+type enrichment struct {
 	hostID string
+	// hostIDSource is the kind of source the host ID has been discovered from
+	hostIDSource string
+	attributes   Metadata
+	// report describes how the host ID has been discovered,
+	// nil if discovering the host ID is disabled
+	report *discoveryReport
 }
 
-func (rp *dynatraceProcessor) processTraces(ctx context.Context, td ptrace.Traces) (ptrace.Traces, error) {
-	if len(rp.hostID) == 0 {
-		return td, nil
+// metricsConfig holds the settings of the metric specific processing steps
+type metricsConfig struct {
+	normalize             bool
+	originalNameAttribute string
+	compat                bool
+	summaryMode           SummaryMode
+}
+
+// newDynatraceProcessor creates a processor for the given config,
+// discovering attributes with the options of the given factory,
+// or the defaults if nil
+func newDynatraceProcessor(ctx context.Context, set processor.Settings, cfg *Config, f *factory) (*dynatraceProcessor, error) {
+	engine, err := acquireEngine(ctx, set, cfg, f)
+	if err != nil {
+		return nil, err
 	}
+	proc := &dynatraceProcessor{
+		logger:          set.Logger,
+		onMissingHostID: cfg.OnMissingHostID,
+		actions:         attributeActions{defaultAction: cfg.Action, overrides: cfg.AttributeActions},
+		telemetry:       engine.telemetry,
+		metrics: metricsConfig{
+			normalize:             cfg.NormalizeMetrics.Enabled,
+			originalNameAttribute: cfg.NormalizeMetrics.OriginalNameAttribute,
+			compat:                cfg.MetricCompat.Enabled,
+			summaryMode:           cfg.MetricCompat.Summary,
+		},
+		correlateTraces: cfg.TraceCorrelation.Enabled,
+		engine:          engine,
+	}
+	if cfg.CumulativeToDelta.Enabled {
+		proc.delta = newDeltaConverter(set.Logger, cfg.CumulativeToDelta.MaxStreams, cfg.CumulativeToDelta.MaxStaleness)
+	}
+	if cfg.SpanAttributes.Enabled {
+		proc.spanAttributes = &spanAttributes{
+			defaultServiceName: cfg.SpanAttributes.DefaultServiceName,
+			keepDeprecated:     cfg.SpanAttributes.KeepDeprecated,
+		}
+	}
+	if cfg.Severity.Enabled {
+		if proc.severity, err = newSeverityInference(cfg.Severity); err != nil {
+			return nil, errors.Join(err, engine.release(proc))
+		}
+	}
+	if cfg.LogLimits.Enabled {
+		proc.logLimits = &logLimits{
+			maxContentLength:        cfg.LogLimits.MaxContentLength,
+			maxAttributes:           cfg.LogLimits.MaxAttributes,
+			maxAttributeValueLength: cfg.LogLimits.MaxAttributeValueLength,
+		}
+	}
+	if cfg.CardinalityLimit.Enabled {
+		proc.cardinality = newCardinalityLimiter(set.Logger, engine.telemetry, cfg.CardinalityLimit)
+	}
+	return proc, nil
+}
+
+// start registers the processor with the shared engine, launching the
+// background re-evaluation of the host ID along with the first processor
+func (rp *dynatraceProcessor) start(_ context.Context, host component.Host) error {
+	rp.engine.start(rp, host)
+	return nil
+}
+
+// shutdown releases the shared engine, stopping the status reporting to
+// the host of the processor and, along with the last processor, the
+// background re-evaluation of the host ID
+func (rp *dynatraceProcessor) shutdown(_ context.Context) error {
+	var err error
+	rp.released.Do(func() {
+		err = rp.engine.release(rp)
+	})
+	return err
+}
+
+// resolveEnrichmentFiles determines the files to evaluate.
+// File paths specified via the context take precedence over the
+// configured ones. If none are configured, the defaults are getting used.
+func resolveEnrichmentFiles(ctx context.Context, cfg *Config) enrichmentFiles {
+	files := enrichmentFilesFromContext(ctx)
+	if _, ok := metaDataFilePathsFromContext(ctx); !ok && cfg.MetaDataFiles != nil {
+		files.metaData = cfg.MetaDataFiles
+	}
+	if _, ok := filePathsFromContext(ctx, CtxKeyRuxitHostIDFilePaths); !ok && cfg.RuxitHostIDFiles != nil {
+		files.ruxitHostID = cfg.RuxitHostIDFiles
+	}
+	return files
+}
+
+// discoverEnrichment evaluates the attributes to add based on the given config.
+// The hostname is getting looked up via the given function.
+func discoverEnrichment(ctx context.Context, cfg *Config, files enrichmentFiles, sources []HostIDSource, hostname func() (string, error)) *enrichment {
+	e := &enrichment{hostIDSource: hostIDSourceNone}
+	if cfg.Metadata {
+		if hostID, ok := hostIDFromContext(ctx); ok {
+			e.report = &discoveryReport{hostID: hostID, source: hostIDSourceContext, decision: "host ID specified via the context"}
+		} else {
+			report := discoverHostID(ctx, sources)
+			e.report = &report
+		}
+		e.hostID, e.hostIDSource = e.report.hostID, e.report.source
+	}
+	if cfg.Properties.Enabled {
+		e.attributes = files.evalMetadata().filter(cfg.Properties.Include, cfg.Properties.Exclude)
+	}
+	if e.report != nil && len(e.report.attributes) > 0 {
+		e.merge(e.report.attributes, "", false)
+	}
+	if cfg.Env.Enabled {
+		hostIDSource := ""
+		if cfg.Metadata {
+			hostIDSource = hostIDSourceEnv
+		}
+		e.merge(evalMetadataFromEnv(os.Environ(), cfg.Env.Prefix, cfg.Env.Mapping), hostIDSource, cfg.Env.Precedence == PrecedenceEnv)
+		if e.report != nil && e.hostIDSource == hostIDSourceEnv {
+			e.report.hostID, e.report.source = e.hostID, e.hostIDSource
+			e.report.decision = "host ID found in the environment variables"
+		}
+	}
+	if cfg.Kubernetes.Enabled {
+		e.merge(evalMetadataFromKubernetes(files.fsys, cfg.Kubernetes.Files, cfg.Kubernetes.NamespaceFile), "", false)
+	}
+	if len(e.hostID) == 0 && len(e.attributes[KeyEntityHost]) == 0 {
+		e.merge(evalHostIdentity(files.fsys, hostname, cfg.HostIdentity), "", false)
+	}
+	if len(cfg.ResourceAttributes) > 0 {
+		if e.attributes == nil {
+			e.attributes = Metadata{}
+		}
+		maps.Copy(e.attributes, cfg.ResourceAttributes)
+		// a configured `dt.entity.host` takes precedence over the discovered one
+		if hostID, found := cfg.ResourceAttributes[KeyEntityHost]; found {
+			e.hostID, e.hostIDSource = hostID, hostIDSourceConfig
+			if e.report != nil {
+				e.report.hostID, e.report.source = e.hostID, e.hostIDSource
+				e.report.decision = "host ID configured via resource_attributes"
+			}
+		}
+	}
+	return e
+}
+
+// merge adds the given attributes. If `override` is true, they replace
+// attributes already discovered, otherwise only missing ones are getting added.
+// If `hostIDSource` isn't empty, a valid `dt.entity.host` is getting used
+// as HostID, discovered from that kind of source.
+func (e *enrichment) merge(attributes Metadata, hostIDSource string, override bool) {
+	if hostIDSource != "" && reHostID.MatchString(attributes[KeyEntityHost]) {
+		if override || len(e.hostID) == 0 {
+			e.hostID, e.hostIDSource = attributes[KeyEntityHost], hostIDSource
+		}
+	}
+	if e.attributes == nil {
+		e.attributes = Metadata{}
+	}
+	for key, value := range attributes {
+		if _, found := e.attributes[key]; found && !override {
+			continue
+		}
+		e.attributes[key] = value
+	}
+}
+
+func (e *enrichment) equal(other *enrichment) bool {
+	return e.hostID == other.hostID && e.hostIDSource == other.hostIDSource && maps.Equal(e.attributes, other.attributes)
+}
+
+// enrich adds the discovered attributes to the given resource attributes.
+// Attributes already present on the resource are getting treated
+// according to the configured actions. Returns whether `dt.entity.host`
+// has been added, has been left untouched or hasn't been discovered.
+func (rp *dynatraceProcessor) enrich(e *enrichment, attrs pcommon.Map) enrichmentOutcome {
+	hostID := e.hostID
+	if len(hostID) == 0 {
+		hostID = e.attributes[KeyEntityHost]
+	}
+	outcome := outcomeNoHostID
+	if len(hostID) > 0 {
+		if rp.actions.apply(attrs, KeyEntityHost, hostID) {
+			outcome = outcomeEnriched
+		} else {
+			outcome = outcomeSkipped
+		}
+	}
+	for key, value := range e.attributes {
+		if key == KeyEntityHost {
+			continue
+		}
+		rp.actions.apply(attrs, key, value)
+	}
+	return outcome
+}
+
+func (rp *dynatraceProcessor) processTraces(ctx context.Context, td ptrace.Traces) (ptrace.Traces, error) {
+	e := rp.engine.current.Load()
+	var counts enrichmentCounts
 	rss := td.ResourceSpans()
+	missing := 0
 	for i := 0; i < rss.Len(); i++ {
 		attrs := rss.At(i).Resource().Attributes()
-		if _, found := attrs.Get(string(MetaDataKeyDTEntityHost)); found {
-			continue
+		counts.add(rp.enrich(e, attrs))
+		if lacksHostID(attrs) {
+			missing++
 		}
-		attrs.PutStr(string(MetaDataKeyDTEntityHost), rp.hostID)
+	}
+	rp.telemetry.recordEnrichment(ctx, signalTraces, counts)
+	if err := rp.checkHostID(missing, rss.Len()); err != nil {
+		return td, err
+	}
+	if rp.spanAttributes != nil {
+		rp.spanAttributes.apply(td)
 	}
 	return td, nil
 }
 
 func (rp *dynatraceProcessor) processMetrics(ctx context.Context, md pmetric.Metrics) (pmetric.Metrics, error) {
-	if len(rp.hostID) == 0 {
-		return md, nil
-	}
+	e := rp.engine.current.Load()
+	var counts enrichmentCounts
 	rms := md.ResourceMetrics()
+	missing := 0
 	for i := 0; i < rms.Len(); i++ {
 		attrs := rms.At(i).Resource().Attributes()
-		if _, found := attrs.Get(string(MetaDataKeyDTEntityHost)); found {
-			continue
+		counts.add(rp.enrich(e, attrs))
+		if lacksHostID(attrs) {
+			missing++
 		}
-		attrs.PutStr(string(MetaDataKeyDTEntityHost), rp.hostID)
+	}
+	rp.telemetry.recordEnrichment(ctx, signalMetrics, counts)
+	if err := rp.checkHostID(missing, rms.Len()); err != nil {
+		return md, err
+	}
+	if rp.metrics.compat {
+		rp.makeMetricsCompatible(ctx, md)
+	}
+	if rp.metrics.normalize {
+		rp.normalizeMetrics(ctx, md)
+	}
+	if rp.delta != nil {
+		rp.delta.convert(md)
+	}
+	if rp.cardinality != nil {
+		rp.cardinality.limitCardinality(ctx, md)
 	}
 	return md, nil
 }
 
 func (rp *dynatraceProcessor) processLogs(ctx context.Context, ld plog.Logs) (plog.Logs, error) {
-	if len(rp.hostID) == 0 {
-		return ld, nil
-	}
+	e := rp.engine.current.Load()
+	var counts enrichmentCounts
 	rls := ld.ResourceLogs()
+	missing := 0
 	for i := 0; i < rls.Len(); i++ {
 		attrs := rls.At(i).Resource().Attributes()
-		if _, found := attrs.Get(string(MetaDataKeyDTEntityHost)); found {
-			continue
+		counts.add(rp.enrich(e, attrs))
+		if lacksHostID(attrs) {
+			missing++
 		}
-		attrs.PutStr(string(MetaDataKeyDTEntityHost), rp.hostID)
+	}
+	rp.telemetry.recordEnrichment(ctx, signalLogs, counts)
+	if err := rp.checkHostID(missing, rls.Len()); err != nil {
+		return ld, err
+	}
+	if rp.severity != nil {
+		rp.inferSeverity(ld)
+	}
+	if rp.correlateTraces {
+		correlateTraces(ld)
+	}
+	if rp.logLimits != nil {
+		rp.limitLogs(ctx, ld)
 	}
 	return ld, nil
 }
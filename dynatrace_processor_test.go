@@ -19,6 +19,8 @@ package dynatraceprocessor_test
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -93,6 +95,9 @@ func TestDynatraceProcessorAttributesInsert(t *testing.T) {
 			factory := dynatraceprocessor.NewFactory()
 			rtp, err := factory.CreateTraces(ctx, processortest.NewNopSettings(), tt.config, ttn)
 			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, rtp.Shutdown(ctx))
+			}()
 			assert.True(t, rtp.Capabilities().MutatesData)
 
 			sourceTraceData := generateTraceData(tt.sourceAttributes)
@@ -107,6 +112,9 @@ func TestDynatraceProcessorAttributesInsert(t *testing.T) {
 			tmn := new(consumertest.MetricsSink)
 			rmp, err := factory.CreateMetrics(ctx, processortest.NewNopSettings(), tt.config, tmn)
 			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, rmp.Shutdown(ctx))
+			}()
 			assert.True(t, rtp.Capabilities().MutatesData)
 
 			sourceMetricData := generateMetricData(tt.sourceAttributes)
@@ -121,6 +129,9 @@ func TestDynatraceProcessorAttributesInsert(t *testing.T) {
 			tln := new(consumertest.LogsSink)
 			rlp, err := factory.CreateLogs(ctx, processortest.NewNopSettings(), tt.config, tln)
 			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, rlp.Shutdown(ctx))
+			}()
 			assert.True(t, rtp.Capabilities().MutatesData)
 
 			sourceLogData := generateLogData(tt.sourceAttributes)
@@ -134,6 +145,194 @@ func TestDynatraceProcessorAttributesInsert(t *testing.T) {
 	}
 }
 
+func TestDynatraceProcessorPropertiesInsert(t *testing.T) {
+	const properties = `dt.entity.host=HOST-2EF98EFF909EE3F6
+dt.entity.process_group_instance=PROCESS_GROUP_INSTANCE-1A2B3C4D5E6F7A8B
+dt.host_group.id=production
+dt.smartscape.process=PROCESS_GROUP_INSTANCE-1A2B3C4D5E6F7A8B`
+
+	tests := []struct {
+		name             string
+		config           *dynatraceprocessor.Config
+		sourceAttributes map[string]string
+		wantAttributes   map[string]string
+	}{
+		{
+			name:             "all_keys",
+			config:           &dynatraceprocessor.Config{Properties: dynatraceprocessor.PropertiesConfig{Enabled: true}},
+			sourceAttributes: map[string]string{},
+			wantAttributes: map[string]string{
+				"dt.entity.host":                   "HOST-2EF98EFF909EE3F6",
+				"dt.entity.process_group_instance": "PROCESS_GROUP_INSTANCE-1A2B3C4D5E6F7A8B",
+				"dt.host_group.id":                 "production",
+				"dt.smartscape.process":            "PROCESS_GROUP_INSTANCE-1A2B3C4D5E6F7A8B",
+			},
+		},
+		{
+			name: "include",
+			config: &dynatraceprocessor.Config{Properties: dynatraceprocessor.PropertiesConfig{
+				Enabled: true,
+				Include: []string{"dt.host_group.id", "dt.entity.host"},
+			}},
+			sourceAttributes: map[string]string{},
+			wantAttributes: map[string]string{
+				"dt.entity.host":   "HOST-2EF98EFF909EE3F6",
+				"dt.host_group.id": "production",
+			},
+		},
+		{
+			name: "include_and_exclude",
+			config: &dynatraceprocessor.Config{Properties: dynatraceprocessor.PropertiesConfig{
+				Enabled: true,
+				Include: []string{"dt.host_group.id", "dt.entity.host"},
+				Exclude: []string{"dt.entity.host"},
+			}},
+			sourceAttributes: map[string]string{},
+			wantAttributes: map[string]string{
+				"dt.host_group.id": "production",
+			},
+		},
+		{
+			name: "existing_attributes_remain_untouched",
+			config: &dynatraceprocessor.Config{Properties: dynatraceprocessor.PropertiesConfig{
+				Enabled: true,
+				Exclude: []string{"dt.entity.process_group_instance", "dt.smartscape.process"},
+			}},
+			sourceAttributes: map[string]string{"dt.host_group.id": "staging"},
+			wantAttributes: map[string]string{
+				"dt.entity.host":   "HOST-2EF98EFF909EE3F6",
+				"dt.host_group.id": "staging",
+			},
+		},
+		{
+			name:             "disabled",
+			config:           &dynatraceprocessor.Config{},
+			sourceAttributes: map[string]string{},
+			wantAttributes:   map[string]string{},
+		},
+	}
+
+	propertiesFile := filepath.Join(t.TempDir(), "dt_metadata.properties")
+	require.NoError(t, os.WriteFile(propertiesFile, []byte(properties), 0o600))
+	ctx := context.WithValue(context.Background(), dynatraceprocessor.CtxKeyMetaDataPropertiesFilePaths, []string{propertiesFile})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory := dynatraceprocessor.NewFactory()
+
+			ttn := new(consumertest.TracesSink)
+			rtp, err := factory.CreateTraces(ctx, processortest.NewNopSettings(), tt.config, ttn)
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, rtp.Shutdown(ctx))
+			}()
+			require.NoError(t, rtp.ConsumeTraces(ctx, generateTraceData(tt.sourceAttributes)))
+			require.Len(t, ttn.AllTraces(), 1)
+			assert.NoError(t, ptracetest.CompareTraces(generateTraceData(tt.wantAttributes), ttn.AllTraces()[0]))
+
+			tmn := new(consumertest.MetricsSink)
+			rmp, err := factory.CreateMetrics(ctx, processortest.NewNopSettings(), tt.config, tmn)
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, rmp.Shutdown(ctx))
+			}()
+			require.NoError(t, rmp.ConsumeMetrics(ctx, generateMetricData(tt.sourceAttributes)))
+			require.Len(t, tmn.AllMetrics(), 1)
+			assert.NoError(t, pmetrictest.CompareMetrics(generateMetricData(tt.wantAttributes), tmn.AllMetrics()[0]))
+
+			tln := new(consumertest.LogsSink)
+			rlp, err := factory.CreateLogs(ctx, processortest.NewNopSettings(), tt.config, tln)
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, rlp.Shutdown(ctx))
+			}()
+			require.NoError(t, rlp.ConsumeLogs(ctx, generateLogData(tt.sourceAttributes)))
+			require.Len(t, tln.AllLogs(), 1)
+			assert.NoError(t, plogtest.CompareLogs(generateLogData(tt.wantAttributes), tln.AllLogs()[0]))
+		})
+	}
+}
+
+func TestDynatraceProcessorConfiguredFiles(t *testing.T) {
+	dir := t.TempDir()
+	metaDataFilePath := filepath.Join(dir, "dt_metadata.json")
+	require.NoError(t, os.WriteFile(metaDataFilePath, []byte(`{"dt.host_group.id": "production"}`), 0o600))
+	ruxitHostIDFilePath := filepath.Join(dir, "ruxithost.id")
+	require.NoError(t, os.WriteFile(ruxitHostIDFilePath, []byte("AAF98EFF909EE3F6"), 0o600))
+
+	cfg := &dynatraceprocessor.Config{
+		Metadata:         true,
+		MetaDataFiles:    []string{filepath.Join(dir, "missing.properties"), metaDataFilePath},
+		RuxitHostIDFiles: []string{ruxitHostIDFilePath},
+		Properties:       dynatraceprocessor.PropertiesConfig{Enabled: true},
+	}
+
+	sink := new(consumertest.LogsSink)
+	lp, err := dynatraceprocessor.NewFactory().CreateLogs(context.Background(), processortest.NewNopSettings(), cfg, sink)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, lp.Shutdown(context.Background()))
+	}()
+	require.NoError(t, lp.ConsumeLogs(context.Background(), generateLogData(nil)))
+	require.Len(t, sink.AllLogs(), 1)
+	assert.NoError(t, plogtest.CompareLogs(generateLogData(map[string]string{
+		dynatraceprocessor.KeyEntityHost: "HOST-AAF98EFF909EE3F6",
+		"dt.host_group.id":               "production",
+	}), sink.AllLogs()[0]))
+}
+
+func TestDynatraceProcessorResourceAttributes(t *testing.T) {
+	propertiesFile := filepath.Join(t.TempDir(), "dt_metadata.properties")
+	require.NoError(t, os.WriteFile(propertiesFile, []byte("dt.host_group.id=production\ndt.cost.product=discovered"), 0o600))
+	ctx := context.WithValue(context.Background(), dynatraceprocessor.CtxKeyMetaDataPropertiesFilePaths, []string{propertiesFile})
+
+	cfg := &dynatraceprocessor.Config{
+		Properties: dynatraceprocessor.PropertiesConfig{Enabled: true},
+		ResourceAttributes: map[string]string{
+			"dt.security_context": "team-a",
+			"dt.cost.costcenter":  "4711",
+			"dt.cost.product":     "webshop",
+		},
+	}
+
+	sink := new(consumertest.MetricsSink)
+	mp, err := dynatraceprocessor.NewFactory().CreateMetrics(ctx, processortest.NewNopSettings(), cfg, sink)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, mp.Shutdown(ctx))
+	}()
+	require.NoError(t, mp.ConsumeMetrics(ctx, generateMetricData(map[string]string{"dt.security_context": "team-b"})))
+	require.Len(t, sink.AllMetrics(), 1)
+	assert.NoError(t, pmetrictest.CompareMetrics(generateMetricData(map[string]string{
+		"dt.security_context": "team-b",
+		"dt.cost.costcenter":  "4711",
+		"dt.cost.product":     "webshop",
+		"dt.host_group.id":    "production",
+	}), sink.AllMetrics()[0]))
+}
+
+func TestDynatraceProcessorResourceAttributesHostID(t *testing.T) {
+	ctx := context.WithValue(context.Background(), dynatraceprocessor.MetaDataKeyDTEntityHost, "HOST-AAF98EFF909EE3F6")
+
+	cfg := &dynatraceprocessor.Config{
+		Metadata:           true,
+		ResourceAttributes: map[string]string{dynatraceprocessor.KeyEntityHost: "HOST-BBF98EFF909EE3F6"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	sink := new(consumertest.MetricsSink)
+	mp, err := dynatraceprocessor.NewFactory().CreateMetrics(ctx, processortest.NewNopSettings(), cfg, sink)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, mp.Shutdown(ctx))
+	}()
+	require.NoError(t, mp.ConsumeMetrics(ctx, generateMetricData(nil)))
+	require.Len(t, sink.AllMetrics(), 1)
+	assert.NoError(t, pmetrictest.CompareMetrics(generateMetricData(map[string]string{
+		dynatraceprocessor.KeyEntityHost: "HOST-BBF98EFF909EE3F6",
+	}), sink.AllMetrics()[0]))
+}
+
 func generateTraceData(attributes map[string]string) ptrace.Traces {
 	td := testdata.GenerateTracesOneSpanNoResource()
 	if attributes == nil {
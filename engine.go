@@ -0,0 +1,171 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/processor"
+	"go.uber.org/zap"
+)
+
+// enrichmentEngine discovers the attributes to add to signals and keeps
+// them up to date. A single engine is getting shared by the traces, metrics
+// and logs processors created from the same config, hence the host ID is
+// getting discovered, watched and reported only once per config.
+type enrichmentEngine struct {
+	// cfg is the config the engine is getting shared for
+	cfg             *Config
+	logger          *zap.Logger
+	metadata        bool
+	refreshInterval time.Duration
+	watch           WatchConfig
+	files           enrichmentFiles
+	telemetry       *processorTelemetry
+	discover        func() *enrichment
+	current         atomic.Pointer[enrichment]
+
+	// mu guards the fields below, which track the processors
+	// sharing the engine and the hosts they have been started with
+	mu      sync.Mutex
+	refs    int
+	hosts   map[*dynatraceProcessor]component.Host
+	started bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+var (
+	enginesMu sync.Mutex
+	// engines holds the engines currently in use, by config
+	engines = map[*Config]*enrichmentEngine{}
+)
+
+// acquireEngine returns the engine for the given config, creating it with
+// the options of the given factory, or the defaults if nil, if no processor
+// created from that config is in use yet.
+// Each acquired engine has to be released via `release`.
+func acquireEngine(ctx context.Context, set processor.Settings, cfg *Config, f *factory) (*enrichmentEngine, error) {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	engine, found := engines[cfg]
+	if !found {
+		var err error
+		if engine, err = newEnrichmentEngine(ctx, set, cfg, f); err != nil {
+			return nil, err
+		}
+		engines[cfg] = engine
+	}
+	engine.mu.Lock()
+	engine.refs++
+	engine.mu.Unlock()
+	return engine, nil
+}
+
+func newEnrichmentEngine(ctx context.Context, set processor.Settings, cfg *Config, f *factory) (*enrichmentEngine, error) {
+	if f == nil {
+		f = &factory{}
+	}
+	hostname := f.hostname
+	if hostname == nil {
+		hostname = os.Hostname
+	}
+	files := resolveEnrichmentFiles(ctx, cfg)
+	files.fsys = f.fsys
+	sources, err := selectHostIDSources(append(files.hostIDSources(), f.hostIDSources...), cfg.Sources)
+	if err != nil {
+		return nil, err
+	}
+	telemetry, err := newProcessorTelemetry(set.TelemetrySettings)
+	if err != nil {
+		return nil, err
+	}
+	engine := &enrichmentEngine{
+		cfg:             cfg,
+		logger:          set.Logger,
+		metadata:        cfg.Metadata,
+		refreshInterval: cfg.RefreshInterval,
+		watch:           cfg.Watch,
+		files:           files,
+		telemetry:       telemetry,
+		hosts:           map[*dynatraceProcessor]component.Host{},
+		discover: func() *enrichment {
+			return discoverEnrichment(ctx, cfg, files, sources, hostname)
+		},
+	}
+	engine.current.Store(engine.discover())
+	if report := engine.current.Load().report; report != nil {
+		report.log(engine.logger)
+	}
+	if cfg.RequireHostID {
+		if err := requireHostID(engine.current.Load()); err != nil {
+			return nil, err
+		}
+	}
+	if err := telemetry.registerHostIDDiscovery(engine.current.Load); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// start reports the status of the host ID discovery to the host of the
+// given processor. The background re-evaluation of the host ID is getting
+// launched along with the first processor sharing the engine.
+func (en *enrichmentEngine) start(owner *dynatraceProcessor, host component.Host) {
+	en.mu.Lock()
+	defer en.mu.Unlock()
+	en.hosts[owner] = host
+	en.reportStatusTo(host, en.current.Load())
+	if en.started {
+		return
+	}
+	en.started = true
+	en.done = make(chan struct{})
+	en.startRefreshing(en.done)
+}
+
+// release stops reporting the status to the host of the given processor.
+// The background re-evaluation of the host ID and the reporting of the
+// gauges are getting stopped once the last processor sharing the engine
+// has been shut down.
+func (en *enrichmentEngine) release(owner *dynatraceProcessor) error {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	en.mu.Lock()
+	delete(en.hosts, owner)
+	en.refs--
+	last := en.refs <= 0
+	if last && en.done != nil {
+		close(en.done)
+		en.done = nil
+	}
+	en.mu.Unlock()
+	if !last {
+		return nil
+	}
+	en.wg.Wait()
+	if engines[en.cfg] == en {
+		delete(engines, en.cfg)
+	}
+	return en.telemetry.unregister()
+}
@@ -0,0 +1,161 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/processor/processortest"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func sharedEngine(cfg *Config) *enrichmentEngine {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	return engines[cfg]
+}
+
+func TestEngineIsSharedPerConfig(t *testing.T) {
+	ruxitHostIDFilePath := filepath.Join(t.TempDir(), "ruxithost.id")
+	require.NoError(t, os.WriteFile(ruxitHostIDFilePath, []byte("AAF98EFF909EE3F6"), 0o600))
+	ctx := newWatchTestContext(ruxitHostIDFilePath)
+
+	set, telemetry := newTestTelemetry()
+	core, logs := observer.New(zapcore.InfoLevel)
+	set.Logger = zap.New(core)
+	cfg := createDefaultConfig().(*Config)
+	cfg.Metadata = true
+	cfg.Watch = WatchConfig{Enabled: true, Debounce: 20 * time.Millisecond}
+
+	factory := NewFactory()
+	tp, err := factory.CreateTraces(ctx, set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	mp, err := factory.CreateMetrics(ctx, set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	lp, err := factory.CreateLogs(ctx, set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	processors := []component.Component{tp, mp, lp}
+	for _, p := range processors {
+		require.NoError(t, p.Start(ctx, componenttest.NewNopHost()))
+	}
+
+	engine := sharedEngine(cfg)
+	require.NotNil(t, engine)
+	assert.Equal(t, 3, engine.refs)
+	assert.Len(t, engine.hosts, 3)
+	assert.Equal(t, 1, logs.FilterMessage("Dynatrace host ID discovery finished").Len())
+	assert.Equal(t, map[string]int64{HostIDSourceRuxitHostID: 1}, telemetry.gauge(t, "processor_dynatrace_host_id_discovered", "source"))
+
+	// a single watcher notices the change, hence it's getting logged once
+	require.NoError(t, os.WriteFile(ruxitHostIDFilePath, []byte("BBF98EFF909EE3F6"), 0o600))
+	assert.Eventually(t, func() bool {
+		return engine.current.Load().hostID == "HOST-BBF98EFF909EE3F6"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, logs.FilterMessage("Dynatrace enrichment changed").Len())
+
+	// a processor created from a different config gets its own engine
+	other := createDefaultConfig().(*Config)
+	other.MetaDataFiles = []string{}
+	other.RuxitHostIDFiles = []string{}
+	op, err := newDynatraceProcessor(context.Background(), set, other, nil)
+	require.NoError(t, err)
+	assert.NotSame(t, engine, op.engine)
+	require.NoError(t, op.shutdown(context.Background()))
+
+	// the engine is getting released along with the last processor
+	require.NoError(t, tp.Shutdown(ctx))
+	require.NoError(t, mp.Shutdown(ctx))
+	assert.Same(t, engine, sharedEngine(cfg))
+	assert.Len(t, engine.hosts, 1)
+	assert.Equal(t, map[string]int64{HostIDSourceRuxitHostID: 1}, telemetry.gauge(t, "processor_dynatrace_host_id_discovered", "source"))
+	require.NoError(t, lp.Shutdown(ctx))
+	assert.Nil(t, sharedEngine(cfg))
+	assert.Empty(t, telemetry.gauge(t, "processor_dynatrace_host_id_discovered", "source"))
+}
+
+func TestEngineIsNotSharedOnError(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Metadata = true
+	cfg.RequireHostID = true
+	cfg.MetaDataFiles = []string{}
+	cfg.RuxitHostIDFiles = []string{}
+
+	_, err := NewFactory().CreateTraces(context.Background(), processortest.NewNopSettings(), cfg, consumertest.NewNop())
+	require.ErrorIs(t, err, errNoHostID)
+	assert.Nil(t, sharedEngine(cfg))
+}
+
+func TestEngineIsReleasedOnProcessorError(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetaDataFiles = []string{}
+	cfg.RuxitHostIDFiles = []string{}
+
+	// the processor helper fails creating its own counters,
+	// after the engine has been acquired
+	set := processortest.NewNopSettings()
+	set.LeveledMeterProvider = func(configtelemetry.Level) metric.MeterProvider {
+		return failingMeterProvider{MeterProvider: noop.NewMeterProvider(), prefix: "otelcol_processor_"}
+	}
+
+	factory := NewFactory()
+	_, err := factory.CreateTraces(context.Background(), set, cfg, consumertest.NewNop())
+	require.ErrorContains(t, err, "otelcol_processor_")
+	_, err = factory.CreateMetrics(context.Background(), set, cfg, consumertest.NewNop())
+	require.Error(t, err)
+	_, err = factory.CreateLogs(context.Background(), set, cfg, consumertest.NewNop())
+	require.Error(t, err)
+	assert.Nil(t, sharedEngine(cfg))
+}
+
+// failingMeterProvider fails creating counters whose name has the given prefix
+type failingMeterProvider struct {
+	metric.MeterProvider
+	prefix string
+}
+
+func (mp failingMeterProvider) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	return failingMeter{Meter: mp.MeterProvider.Meter(name, opts...), prefix: mp.prefix}
+}
+
+type failingMeter struct {
+	metric.Meter
+	prefix string
+}
+
+func (m failingMeter) Int64Counter(name string, opts ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	if strings.HasPrefix(name, m.prefix) {
+		return nil, fmt.Errorf("creating %s failed", name)
+	}
+	return m.Meter.Int64Counter(name, opts...)
+}
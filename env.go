@@ -0,0 +1,116 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"strings"
+)
+
+// Precedence defines which source wins if both the enrichment files
+// and the environment variables provide a value for the same attribute
+type Precedence string
+
+const (
+	// PrecedenceFiles prefers the values found in the enrichment files
+	PrecedenceFiles Precedence = "files"
+	// PrecedenceEnv prefers the values found in the environment variables
+	PrecedenceEnv Precedence = "env"
+)
+
+// DefaultEnvPrefix is the prefix of the environment variables
+// getting mapped to resource attributes by default
+const DefaultEnvPrefix = "DT_"
+
+// knownEnvAttributes maps the environment variables set by deployment
+// tooling, which don't follow the generic naming scheme, to the
+// corresponding resource attributes
+var knownEnvAttributes = map[string]string{
+	"DT_HOST_GROUP": "dt.host_group.id",
+}
+
+// sensitiveEnvSubstrings lists the parts of environment variable names
+// hinting at credentials, e.g. `DT_API_TOKEN` set for the Dynatrace
+// exporter. Such variables are never getting mapped.
+var sensitiveEnvSubstrings = []string{"TOKEN", "SECRET", "PASSWORD", "CREDENTIAL"}
+
+// sensitiveEnvPrefixes lists the prefixes of environment variables
+// describing the Dynatrace environment telemetry is getting sent to
+// rather than the monitored entity. Such variables are never getting mapped.
+var sensitiveEnvPrefixes = []string{"DT_API_URL", "DT_TENANT"}
+
+// isSensitiveEnv returns whether the environment variable with the given
+// name may contain credentials or connection details and therefore must
+// not end up as resource attribute
+func isSensitiveEnv(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, substring := range sensitiveEnvSubstrings {
+		if strings.Contains(upper, substring) {
+			return true
+		}
+	}
+	for _, prefix := range sensitiveEnvPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// evalMetadataFromEnv maps the given environment variables with the given
+// prefix to resource attributes.
+// Unless specified otherwise via the given mapping, the prefix is getting
+// stripped and the remaining name is getting converted into lower case
+// with underscores replaced by dots and prefixed with `dt.`,
+// e.g. `DT_ENTITY_HOST` becomes `dt.entity.host`.
+// Variables with an empty value are getting skipped, as well as variables
+// which may contain credentials, e.g. `DT_API_TOKEN`, even if mapped explicitly.
+func evalMetadataFromEnv(environ []string, prefix string, mapping map[string]string) Metadata {
+	metadata := Metadata{}
+	for _, entry := range environ {
+		name, value, found := strings.Cut(entry, "=")
+		if !found || !strings.HasPrefix(name, prefix) || isSensitiveEnv(name) {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		key := envAttributeKey(name, prefix, mapping)
+		if key == "" {
+			continue
+		}
+		metadata[key] = value
+	}
+	return metadata
+}
+
+// envAttributeKey returns the resource attribute key
+// for the environment variable with the given name
+func envAttributeKey(name string, prefix string, mapping map[string]string) string {
+	if key, found := mapping[name]; found {
+		return key
+	}
+	if key, found := knownEnvAttributes[name]; found {
+		return key
+	}
+	suffix := strings.Trim(strings.TrimPrefix(name, prefix), "_")
+	if suffix == "" {
+		return ""
+	}
+	return "dt." + strings.ReplaceAll(strings.ToLower(suffix), "_", ".")
+}
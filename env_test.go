@@ -0,0 +1,157 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/pdatatest/plogtest"
+)
+
+func TestDynatraceProcessorEnv(t *testing.T) {
+	tests := []struct {
+		name           string
+		metadata       bool
+		env            dynatraceprocessor.EnvConfig
+		properties     string
+		wantAttributes map[string]string
+	}{
+		{
+			name:     "default_mapping",
+			metadata: true,
+			env:      dynatraceprocessor.EnvConfig{Enabled: true, Prefix: "DT_"},
+			wantAttributes: map[string]string{
+				dynatraceprocessor.KeyEntityHost: "HOST-AAF98EFF909EE3F6",
+				"dt.host_group.id":               "production",
+				"dt.tags":                        "team=a",
+			},
+		},
+		{
+			name:     "custom_mapping",
+			metadata: true,
+			env: dynatraceprocessor.EnvConfig{
+				Enabled: true,
+				Prefix:  "DT_",
+				Mapping: map[string]string{"DT_TAGS": "dt.custom.tags"},
+			},
+			wantAttributes: map[string]string{
+				dynatraceprocessor.KeyEntityHost: "HOST-AAF98EFF909EE3F6",
+				"dt.host_group.id":               "production",
+				"dt.custom.tags":                 "team=a",
+			},
+		},
+		{
+			name:     "custom_prefix",
+			metadata: true,
+			env:      dynatraceprocessor.EnvConfig{Enabled: true, Prefix: "DYNATRACE_"},
+			wantAttributes: map[string]string{
+				"dt.cost.product": "webshop",
+			},
+		},
+		{
+			name:       "files_take_precedence",
+			metadata:   true,
+			env:        dynatraceprocessor.EnvConfig{Enabled: true, Prefix: "DT_", Precedence: dynatraceprocessor.PrecedenceFiles},
+			properties: "dt.entity.host=HOST-BBF98EFF909EE3F6\ndt.host_group.id=staging",
+			wantAttributes: map[string]string{
+				dynatraceprocessor.KeyEntityHost: "HOST-BBF98EFF909EE3F6",
+				"dt.host_group.id":               "staging",
+				"dt.tags":                        "team=a",
+			},
+		},
+		{
+			name:       "env_takes_precedence",
+			metadata:   true,
+			env:        dynatraceprocessor.EnvConfig{Enabled: true, Prefix: "DT_", Precedence: dynatraceprocessor.PrecedenceEnv},
+			properties: "dt.entity.host=HOST-BBF98EFF909EE3F6\ndt.host_group.id=staging",
+			wantAttributes: map[string]string{
+				dynatraceprocessor.KeyEntityHost: "HOST-AAF98EFF909EE3F6",
+				"dt.host_group.id":               "production",
+				"dt.tags":                        "team=a",
+			},
+		},
+		{
+			name:     "credentials_are_never_mapped",
+			metadata: true,
+			env: dynatraceprocessor.EnvConfig{
+				Enabled: true,
+				Prefix:  "DT_",
+				Mapping: map[string]string{"DT_API_TOKEN": "dt.api.token"},
+			},
+			wantAttributes: map[string]string{
+				dynatraceprocessor.KeyEntityHost: "HOST-AAF98EFF909EE3F6",
+				"dt.host_group.id":               "production",
+				"dt.tags":                        "team=a",
+			},
+		},
+		{
+			name:           "disabled",
+			metadata:       true,
+			env:            dynatraceprocessor.EnvConfig{Prefix: "DT_"},
+			wantAttributes: map[string]string{},
+		},
+	}
+
+	t.Setenv("DT_ENTITY_HOST", "HOST-AAF98EFF909EE3F6")
+	t.Setenv("DT_HOST_GROUP", "production")
+	t.Setenv("DT_TAGS", "team=a")
+	t.Setenv("DT_EMPTY", "")
+	t.Setenv("DT_API_TOKEN", "dt0c01.SECRET")
+	t.Setenv("DT_API_URL", "https://abc12345.live.dynatrace.com/api")
+	t.Setenv("DT_TENANTTOKEN", "secret")
+	t.Setenv("DT_CLIENT_SECRET", "secret")
+	t.Setenv("DT_PROXY_PASSWORD", "secret")
+	t.Setenv("DYNATRACE_COST_PRODUCT", "webshop")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			metaDataFilePaths := []string{}
+			if tt.properties != "" {
+				metaDataFilePath := filepath.Join(dir, "dt_metadata.properties")
+				require.NoError(t, os.WriteFile(metaDataFilePath, []byte(tt.properties), 0o600))
+				metaDataFilePaths = append(metaDataFilePaths, metaDataFilePath)
+			}
+
+			cfg := &dynatraceprocessor.Config{
+				Metadata:         tt.metadata,
+				MetaDataFiles:    metaDataFilePaths,
+				RuxitHostIDFiles: []string{},
+				Properties:       dynatraceprocessor.PropertiesConfig{Enabled: true},
+				Env:              tt.env,
+			}
+			require.NoError(t, cfg.Validate())
+
+			sink := new(consumertest.LogsSink)
+			lp, err := dynatraceprocessor.NewFactory().CreateLogs(context.Background(), processortest.NewNopSettings(), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, lp.ConsumeLogs(context.Background(), generateLogData(nil)))
+			require.Len(t, sink.AllLogs(), 1)
+			assert.NoError(t, plogtest.CompareLogs(generateLogData(tt.wantAttributes), sink.AllLogs()[0]))
+		})
+	}
+}
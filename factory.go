@@ -19,6 +19,9 @@ package dynatraceprocessor
 
 import (
 	"context"
+	"errors"
+	"io/fs"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
@@ -28,73 +31,182 @@ import (
 
 var processorCapabilities = consumer.Capabilities{MutatesData: true}
 
+// FactoryOption customizes the factory returned by NewFactory
+type FactoryOption func(*factory)
+
+// WithFileSystem makes the processors read the enrichment files, and any
+// other file attributes are getting discovered from, from the given file
+// system instead of the file system of the current host.
+// Paths are getting looked up relative to the root of the given file system,
+// e.g. `/var/lib/dynatrace/enrichment/dt_metadata.json` as
+// `var/lib/dynatrace/enrichment/dt_metadata.json`.
+// Such files can't be watched, with `watch` enabled they are getting polled.
+func WithFileSystem(fsys fs.FS) FactoryOption {
+	return func(f *factory) {
+		f.fsys = fsys
+	}
+}
+
+// WithHostIDSources registers custom host ID sources, in addition
+// to the built-in ones. They can be referred to by name via `sources`.
+func WithHostIDSources(sources ...HostIDSource) FactoryOption {
+	return func(f *factory) {
+		f.hostIDSources = append(f.hostIDSources, sources...)
+	}
+}
+
+// factory holds the options the processors are getting created with
+type factory struct {
+	// fsys is the file system attributes are getting discovered from,
+	// nil for the file system of the current host
+	fsys fs.FS
+	// hostIDSources lists the custom host ID sources
+	hostIDSources []HostIDSource
+	// hostname looks up the hostname of the current host,
+	// nil for `os.Hostname`
+	hostname func() (string, error)
+}
+
 // NewFactory returns a new factory for the Dynatrace processor.
-func NewFactory() processor.Factory {
+func NewFactory(options ...FactoryOption) processor.Factory {
+	f := &factory{}
+	for _, option := range options {
+		option(f)
+	}
 	return processor.NewFactory(
 		component.MustNewType("dynatrace"),
 		createDefaultConfig,
-		processor.WithTraces(createTracesProcessor, component.StabilityLevelStable),
-		processor.WithMetrics(createMetricsProcessor, component.StabilityLevelStable),
-		processor.WithLogs(createLogsProcessor, component.StabilityLevelStable))
+		processor.WithTraces(f.createTracesProcessor, component.StabilityLevelStable),
+		processor.WithMetrics(f.createMetricsProcessor, component.StabilityLevelStable),
+		processor.WithLogs(f.createLogsProcessor, component.StabilityLevelStable))
 }
 
+const (
+	defaultWatchDebounce      = time.Second
+	defaultDeltaMaxStreams    = 100000
+	defaultDeltaMaxStaleness  = 5 * time.Minute
+	defaultMaxSeriesPerMetric = 1000
+	defaultCardinalityWindow  = time.Hour
+	defaultMaxContentLength   = 65536
+	defaultMaxLogAttributes   = 50
+	defaultMaxAttributeLength = 250
+)
+
 func createDefaultConfig() component.Config {
-	return &Config{}
+	return &Config{
+		MetaDataFiles:    DefaultMetaDataFilePaths(),
+		RuxitHostIDFiles: DefaultRuxitHostIDFilePaths(),
+		Action:           ActionInsert,
+		OnMissingHostID:  MissingHostIDPass,
+		Env: EnvConfig{
+			Prefix:     DefaultEnvPrefix,
+			Precedence: PrecedenceFiles,
+		},
+		NormalizeMetrics: NormalizeMetricsConfig{
+			OriginalNameAttribute: DefaultOriginalNameAttribute,
+		},
+		MetricCompat: MetricCompatConfig{
+			Summary: SummaryModeConvert,
+		},
+		CumulativeToDelta: CumulativeToDeltaConfig{
+			MaxStreams:   defaultDeltaMaxStreams,
+			MaxStaleness: defaultDeltaMaxStaleness,
+		},
+		CardinalityLimit: CardinalityLimitConfig{
+			MaxSeriesPerMetric: defaultMaxSeriesPerMetric,
+			Window:             defaultCardinalityWindow,
+			Action:             CardinalityActionOverflow,
+		},
+		Severity: SeverityConfig{
+			Attributes:   DefaultSeverityAttributes(),
+			BodyPatterns: DefaultSeverityBodyPatterns(),
+		},
+		LogLimits: LogLimitsConfig{
+			MaxContentLength:        defaultMaxContentLength,
+			MaxAttributes:           defaultMaxLogAttributes,
+			MaxAttributeValueLength: defaultMaxAttributeLength,
+		},
+		Kubernetes: KubernetesConfig{
+			Files:         DefaultKubernetesFiles(),
+			NamespaceFile: DefaultKubernetesNamespaceFile,
+		},
+		Watch: WatchConfig{
+			Debounce: defaultWatchDebounce,
+		},
+	}
 }
 
-func createTracesProcessor(
+func (f *factory) createTracesProcessor(
 	ctx context.Context,
 	set processor.Settings,
 	cfg component.Config,
 	nextConsumer consumer.Traces) (processor.Traces, error) {
-	hostID := ""
-	if cfg.(*Config).Metadata {
-		hostID = GetHostID(ctx)
+	proc, err := newDynatraceProcessor(ctx, set, cfg.(*Config), f)
+	if err != nil {
+		return nil, err
 	}
-	proc := &dynatraceProcessor{logger: set.Logger, hostID: hostID}
-	return processorhelper.NewTraces(
+	p, err := processorhelper.NewTraces(
 		ctx,
 		set,
 		cfg,
 		nextConsumer,
 		proc.processTraces,
-		processorhelper.WithCapabilities(processorCapabilities))
+		processorhelper.WithCapabilities(processorCapabilities),
+		processorhelper.WithStart(proc.start),
+		processorhelper.WithShutdown(proc.shutdown))
+	if err != nil {
+		// the engine has been acquired already
+		return nil, errors.Join(err, proc.shutdown(ctx))
+	}
+	return p, nil
 }
 
-func createMetricsProcessor(
+func (f *factory) createMetricsProcessor(
 	ctx context.Context,
 	set processor.Settings,
 	cfg component.Config,
 	nextConsumer consumer.Metrics) (processor.Metrics, error) {
-	hostID := ""
-	if cfg.(*Config).Metadata {
-		hostID = GetHostID(ctx)
+	proc, err := newDynatraceProcessor(ctx, set, cfg.(*Config), f)
+	if err != nil {
+		return nil, err
 	}
-	proc := &dynatraceProcessor{logger: set.Logger, hostID: hostID}
-	return processorhelper.NewMetrics(
+	p, err := processorhelper.NewMetrics(
 		ctx,
 		set,
 		cfg,
 		nextConsumer,
 		proc.processMetrics,
-		processorhelper.WithCapabilities(processorCapabilities))
+		processorhelper.WithCapabilities(processorCapabilities),
+		processorhelper.WithStart(proc.start),
+		processorhelper.WithShutdown(proc.shutdown))
+	if err != nil {
+		// the engine has been acquired already
+		return nil, errors.Join(err, proc.shutdown(ctx))
+	}
+	return p, nil
 }
 
-func createLogsProcessor(
+func (f *factory) createLogsProcessor(
 	ctx context.Context,
 	set processor.Settings,
 	cfg component.Config,
 	nextConsumer consumer.Logs) (processor.Logs, error) {
-	hostID := ""
-	if cfg.(*Config).Metadata {
-		hostID = GetHostID(ctx)
+	proc, err := newDynatraceProcessor(ctx, set, cfg.(*Config), f)
+	if err != nil {
+		return nil, err
 	}
-	proc := &dynatraceProcessor{logger: set.Logger, hostID: hostID}
-	return processorhelper.NewLogs(
+	p, err := processorhelper.NewLogs(
 		ctx,
 		set,
 		cfg,
 		nextConsumer,
 		proc.processLogs,
-		processorhelper.WithCapabilities(processorCapabilities))
+		processorhelper.WithCapabilities(processorCapabilities),
+		processorhelper.WithStart(proc.start),
+		processorhelper.WithShutdown(proc.shutdown))
+	if err != nil {
+		// the engine has been acquired already
+		return nil, errors.Join(err, proc.shutdown(ctx))
+	}
+	return p, nil
 }
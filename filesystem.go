@@ -0,0 +1,53 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// openFile opens the file identified by the parameter `filePath`.
+// Without a file system being given, the file is getting opened on the
+// current host. Otherwise it is getting looked up within the given file
+// system, relative to its root (see `fsPath`).
+func openFile(fsys fs.FS, filePath string) (fs.File, error) {
+	if fsys == nil {
+		return os.Open(filePath)
+	}
+	return fsys.Open(fsPath(filePath))
+}
+
+// readFile reads the file identified by the parameter `filePath`,
+// the same way `openFile` opens it
+func readFile(fsys fs.FS, filePath string) ([]byte, error) {
+	if fsys == nil {
+		return os.ReadFile(filePath)
+	}
+	return fs.ReadFile(fsys, fsPath(filePath))
+}
+
+// fsPath converts a path of the host into a path valid within an `fs.FS`.
+// Backslashes are getting replaced by slashes and leading slashes are
+// getting removed, e.g. `/var/lib/dynatrace/enrichment/dt_metadata.json`
+// becomes `var/lib/dynatrace/enrichment/dt_metadata.json` and
+// `C:\ProgramData\dynatrace` becomes `C:/ProgramData/dynatrace`.
+func fsPath(filePath string) string {
+	return strings.TrimLeft(strings.ReplaceAll(filePath, `\`, "/"), "/")
+}
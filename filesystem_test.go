@@ -0,0 +1,112 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor"
+)
+
+func TestWithFileSystem(t *testing.T) {
+	tests := []struct {
+		name           string
+		files          fstest.MapFS
+		config         func(cfg *dynatraceprocessor.Config)
+		wantAttributes map[string]any
+	}{
+		{
+			name: "metadata_file",
+			files: fstest.MapFS{
+				"var/lib/dynatrace/enrichment/dt_metadata.properties": {Data: []byte("dt.entity.host=HOST-AAF98EFF909EE3F6\ndt.host_group.id=production")},
+			},
+			config: func(cfg *dynatraceprocessor.Config) {
+				cfg.Properties.Enabled = true
+			},
+			wantAttributes: map[string]any{dynatraceprocessor.KeyEntityHost: "HOST-AAF98EFF909EE3F6", "dt.host_group.id": "production"},
+		},
+		{
+			name: "magic_file",
+			files: fstest.MapFS{
+				"dt_metadata_e617c525669e072eebe3d0f08212e8f2.json":  {Data: []byte("/var/lib/dynatrace/enrichment/dt_host_metadata.json")},
+				"var/lib/dynatrace/enrichment/dt_host_metadata.json": {Data: []byte(`{"dt.entity.host": "HOST-BBF98EFF909EE3F6"}`)},
+			},
+			wantAttributes: map[string]any{dynatraceprocessor.KeyEntityHost: "HOST-BBF98EFF909EE3F6"},
+		},
+		{
+			name: "windows_ruxithost_id",
+			files: fstest.MapFS{
+				"C:/ProgramData/dynatrace/oneagent/agent/config/ruxithost.id": {Data: []byte("CCF98EFF909EE3F6")},
+			},
+			wantAttributes: map[string]any{dynatraceprocessor.KeyEntityHost: "HOST-CCF98EFF909EE3F6"},
+		},
+		{
+			name: "kubernetes",
+			files: fstest.MapFS{
+				"etc/podinfo/k8s.cluster.uid":                            {Data: []byte("0a1b2c3d")},
+				"var/run/secrets/kubernetes.io/serviceaccount/namespace": {Data: []byte("shop\n")},
+			},
+			config: func(cfg *dynatraceprocessor.Config) {
+				cfg.Kubernetes.Enabled = true
+			},
+			wantAttributes: map[string]any{dynatraceprocessor.KeyK8sClusterUID: "0a1b2c3d", dynatraceprocessor.KeyK8sNamespaceName: "shop"},
+		},
+		{
+			name: "host_identity",
+			files: fstest.MapFS{
+				"etc/machine-id": {Data: []byte("4c4c4544004a4d1080345ac04f563533")},
+			},
+			config: func(cfg *dynatraceprocessor.Config) {
+				cfg.HostIdentity.MachineID = true
+			},
+			wantAttributes: map[string]any{dynatraceprocessor.KeyHostID: "4c4c4544004a4d1080345ac04f563533"},
+		},
+		{
+			name:           "no_files",
+			files:          fstest.MapFS{},
+			wantAttributes: map[string]any{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory := dynatraceprocessor.NewFactory(dynatraceprocessor.WithFileSystem(tt.files))
+			cfg := factory.CreateDefaultConfig().(*dynatraceprocessor.Config)
+			cfg.Metadata = true
+			if tt.config != nil {
+				tt.config(cfg)
+			}
+
+			sink := new(consumertest.TracesSink)
+			tp, err := factory.CreateTraces(context.Background(), processortest.NewNopSettings(), cfg, sink)
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, tp.Shutdown(context.Background()))
+			}()
+			require.NoError(t, tp.ConsumeTraces(context.Background(), generateTraceData(nil)))
+			require.Len(t, sink.AllTraces(), 1)
+			assert.Equal(t, tt.wantAttributes, sink.AllTraces()[0].ResourceSpans().At(0).Resource().Attributes().AsRaw())
+		})
+	}
+}
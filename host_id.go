@@ -21,9 +21,12 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"os"
+	"encoding/json"
+	"io/fs"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 var reHostID = regexp.MustCompile(`^HOST-[a-fA-F0-9]+$`)
@@ -33,21 +36,44 @@ type CtxKey string
 const KeyEntityHost = "dt.entity.host"
 const MetaDataKeyDTEntityHost = CtxKey(KeyEntityHost)
 const CtxKeyMetaDataPropertiesFilePaths = CtxKey("MetaDataPropertiesFilePaths")
+const CtxKeyMetaDataJSONFilePaths = CtxKey("MetaDataJSONFilePaths")
 const CtxKeyRuxitHostIDFilePaths = CtxKey("RuxitHostIDFilePaths")
 
-var evaluatedHostID = EvalHostID(context.Background())
+// Sources the host ID can get discovered from, besides the HostIDSources
+const (
+	hostIDSourceNone    = "none"
+	hostIDSourceContext = "context"
+	hostIDSourceEnv     = "env"
+	hostIDSourceConfig  = "resource_attributes"
+)
+
+// evaluatedHostID evaluates the HostID upon its first use and
+// remembers it, hence importing the package doesn't access any files
+var evaluatedHostID = sync.OnceValue(func() string {
+	return EvalHostID(context.Background())
+})
 
 // GetHostID attempts to evaluate the HostID based on
 // a selected few configuration files on the current host
 // If none of these files contains valid content or none of these
 // files exists an empty string is getting returned
+// The files are getting evaluated upon the first call only.
 func GetHostID(ctx context.Context) string {
+	if hostID, ok := hostIDFromContext(ctx); ok {
+		return hostID
+	}
+	return evaluatedHostID()
+}
+
+// hostIDFromContext returns the HostID explicitly specified
+// via the given context, if any
+func hostIDFromContext(ctx context.Context) (string, bool) {
 	if value := ctx.Value(MetaDataKeyDTEntityHost); value != nil {
 		if stringValue, ok := value.(string); ok {
-			return stringValue
+			return stringValue, true
 		}
 	}
-	return evaluatedHostID
+	return "", false
 }
 
 // EvalHostID attempts to evaluate the HostID based on
@@ -58,84 +84,162 @@ func GetHostID(ctx context.Context) string {
 // Host ID as expected by Dynatrace, an empty string is
 // getting returned
 func EvalHostID(ctx context.Context) string {
-	hostID := evalHostIDValue(ctx)
-	if reHostID.MatchString(hostID) {
-		return hostID
-	}
-	return ""
+	hostID, _ := enrichmentFilesFromContext(ctx).evalHostID()
+	return hostID
 }
 
-// evalHostIDValue attempts to evaluate the HostID based on
-// a selected few configuration files on the current host
-// If none of these files contains valid content or none of these
-// files exists an empty string is getting returned
-func evalHostIDValue(ctx context.Context) string {
-	var hostID string
-	var err error
-
-	var defaultMetaDataPropertiesFilePaths = []string{
+// DefaultMetaDataFilePaths returns the locations of the
+// `dt_metadata.properties` and `dt_metadata.json` files evaluated
+// by default, in the order they are getting looked at
+func DefaultMetaDataFilePaths() []string {
+	return []string{
 		"dt_metadata_e617c525669e072eebe3d0f08212e8f2.properties",
 		"/var/lib/dynatrace/enrichment/dt_metadata.properties",
+		"dt_metadata_e617c525669e072eebe3d0f08212e8f2.json",
+		"/var/lib/dynatrace/enrichment/dt_metadata.json",
 	}
-	metaDataPropertiesFilePaths := defaultMetaDataPropertiesFilePaths
-	// productive file paths will be unavailable during unit tests
-	// context contains temporary files in that case
-	if value := ctx.Value(CtxKeyMetaDataPropertiesFilePaths); value != nil {
-		if values, ok := value.([]string); ok {
-			metaDataPropertiesFilePaths = values
-		}
-	}
-
-	for _, metaDataPropertiesFilePath := range metaDataPropertiesFilePaths {
-		hostID, err = evalHostIDFromProperties(metaDataPropertiesFilePath)
-		if len(hostID) > 0 && err == nil {
-			return hostID
-		}
-	}
+}
 
-	var defaultRuxitHostIDFilePaths = []string{
+// DefaultRuxitHostIDFilePaths returns the locations of the
+// `ruxithost.id` files evaluated by default, in the order
+// they are getting looked at
+func DefaultRuxitHostIDFilePaths() []string {
+	return []string{
 		"C:\\ProgramData\\dynatrace\\oneagent\\agent\\config\\ruxithost.id",
 		"/var/lib/dynatrace/oneagent/agent/config/ruxithost.id",
 	}
-	ruxitHostIDFilePaths := defaultRuxitHostIDFilePaths
+}
+
+// enrichmentFiles lists the files evaluated in order to determine
+// the HostID and the metadata, in the order they are getting looked at
+type enrichmentFiles struct {
+	// metaData lists `dt_metadata.properties` and `dt_metadata.json` files.
+	// The format is getting determined by the contents, see `evalMetadataFromFile`.
+	metaData []string
+	// ruxitHostID lists `ruxithost.id` files
+	ruxitHostID []string
+	// fsys is the file system the files are getting read from,
+	// nil for the file system of the current host
+	fsys fs.FS
+}
+
+// enrichmentFilesFromContext returns the default enrichment files,
+// unless the given context specifies other file paths.
+func enrichmentFilesFromContext(ctx context.Context) enrichmentFiles {
+	files := enrichmentFiles{}
+	if filePaths, ok := metaDataFilePathsFromContext(ctx); ok {
+		files.metaData = filePaths
+	} else {
+		files.metaData = DefaultMetaDataFilePaths()
+	}
+	if filePaths, ok := filePathsFromContext(ctx, CtxKeyRuxitHostIDFilePaths); ok {
+		files.ruxitHostID = filePaths
+	} else {
+		files.ruxitHostID = DefaultRuxitHostIDFilePaths()
+	}
+	return files
+}
+
+// metaDataFilePathsFromContext returns the `dt_metadata.properties`
+// and `dt_metadata.json` files specified via the given context.
+// If only one of these kinds is specified, no files of the other kind
+// are getting evaluated, i.e. the context replaces all default files.
+func metaDataFilePathsFromContext(ctx context.Context) ([]string, bool) {
 	// productive file paths will be unavailable during unit tests
 	// context contains temporary files in that case
-	if value := ctx.Value(CtxKeyRuxitHostIDFilePaths); value != nil {
+	propertiesFilePaths, propertiesOK := filePathsFromContext(ctx, CtxKeyMetaDataPropertiesFilePaths)
+	jsonFilePaths, jsonOK := filePathsFromContext(ctx, CtxKeyMetaDataJSONFilePaths)
+	if !propertiesOK && !jsonOK {
+		return nil, false
+	}
+	return append(append([]string{}, propertiesFilePaths...), jsonFilePaths...), true
+}
+
+// filePathsFromContext returns the file paths stored
+// in the given context under the given key, if any
+func filePathsFromContext(ctx context.Context, key CtxKey) ([]string, bool) {
+	if value := ctx.Value(key); value != nil {
 		if values, ok := value.([]string); ok {
-			ruxitHostIDFilePaths = values
+			return values, true
 		}
 	}
+	return nil, false
+}
 
-	for _, ruxitHostIDFilePath := range ruxitHostIDFilePaths {
-		hostID, err = evalHostIDFromRuxitHostID(ruxitHostIDFilePath)
-		if len(hostID) > 0 && err == nil {
-			return hostID
+// all returns the locations of all files
+func (files enrichmentFiles) all() []string {
+	var filePaths []string
+	filePaths = append(filePaths, files.metaData...)
+	filePaths = append(filePaths, files.ruxitHostID...)
+	return filePaths
+}
+
+// evalHostID attempts to evaluate the HostID based on the files
+// and returns it together with the kind of file it has been found in.
+// If the evaluated doesn't match the format of a valid
+// Host ID as expected by Dynatrace, an empty string is
+// getting returned
+func (files enrichmentFiles) evalHostID() (string, string) {
+	report := files.discoverHostID()
+	return report.hostID, report.source
+}
+
+// evalMetadata evaluates all key/value pairs contained in the first
+// `dt_metadata.properties` or `dt_metadata.json` file which exists
+// and isn't empty.
+func (files enrichmentFiles) evalMetadata() Metadata {
+	for _, metaDataFilePath := range files.metaData {
+		metadata, err := evalMetadataFromFile(files.fsys, metaDataFilePath)
+		if len(metadata) > 0 && err == nil {
+			return metadata
 		}
 	}
-	return ""
+	return Metadata{}
 }
 
-// evalHostIDFromProperties evaluates the HostID based on a "magic"
-// file. That file doesn't exist on the files system.
+// evalHostIDFromMetaData evaluates the HostID based on a
+// `dt_metadata.properties` or `dt_metadata.json` file.
+// The "magic" variant of such a file doesn't exist on the files system.
 // OneAgent ensures that the current process is able to read that file.
 // If OneAgent isn't running or isn't injected into the running process
 // an empty string is getting returned.
 // If the contents of the file identified by the parameter `filePath`
 // doesn't contain the expected contents an empty string is getting returned
-func evalHostIDFromProperties(filePath string) (string, error) {
-	content, err := os.ReadFile(filePath)
+func evalHostIDFromMetaData(fsys fs.FS, filePath string) (string, error) {
+	metadata, err := evalMetadataFromFile(fsys, filePath)
 	if err != nil {
 		return "", err
 	}
-	sContent := strings.TrimSpace(string(content))
-	if strings.HasSuffix(string(sContent), ".properties") {
-		content, err = os.ReadFile(string(sContent))
-		if err != nil {
-			return "", err
-		}
-		sContent = strings.TrimSpace(string(content))
+	return metadata[KeyEntityHost], nil
+}
+
+// evalMetadataFromFile parses all key/value pairs contained in a
+// `dt_metadata.properties` or `dt_metadata.json` file.
+// If the file identified by the parameter `filePath` contains just the
+// path to another `.properties` or `.json` file (as the "magic" files
+// provided by OneAgent do), the contents of that file are getting
+// parsed instead.
+// Contents starting with `{` are getting parsed as JSON,
+// anything else as properties.
+func evalMetadataFromFile(fsys fs.FS, filePath string) (Metadata, error) {
+	content, err := readEnrichmentFile(fsys, filePath)
+	if err != nil {
+		return nil, err
 	}
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return parseMetadataJSON(content)
+	}
+	return parseMetadataProperties(content), nil
+}
 
+// parseMetadataProperties parses all key/value pairs contained
+// in the contents of a `dt_metadata.properties` file.
+// Empty lines, comments and lines not containing a `=` are getting skipped.
+// If a key occurs more than once, its first occurrence wins.
+func parseMetadataProperties(content []byte) Metadata {
+	sContent := strings.TrimSpace(string(content))
+
+	metadata := Metadata{}
 	buf := bytes.NewBufferString(sContent)
 	scanner := bufio.NewScanner(buf)
 	for scanner.Scan() {
@@ -148,12 +252,69 @@ func evalHostIDFromProperties(filePath string) (string, error) {
 			continue
 		}
 		key := strings.TrimSpace(parts[0])
-		if key == string(MetaDataKeyDTEntityHost) {
-			return strings.TrimSpace(parts[1]), nil
+		if _, found := metadata[key]; found || key == "" {
+			continue
 		}
+		metadata[key] = strings.TrimSpace(parts[1])
 	}
 
-	return "", nil
+	return metadata
+}
+
+// parseMetadataJSON parses all key/value pairs contained
+// in the contents of a `dt_metadata.json` file.
+// The contents are expected to be a flat JSON object. Numbers and booleans
+// are getting converted into strings, nested objects, arrays and nulls
+// are getting skipped.
+func parseMetadataJSON(content []byte) (Metadata, error) {
+	var values map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	metadata := Metadata{}
+	for key, value := range values {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			metadata[key] = strings.TrimSpace(v)
+		case json.Number:
+			metadata[key] = v.String()
+		case bool:
+			metadata[key] = strconv.FormatBool(v)
+		}
+	}
+	return metadata, nil
+}
+
+// readEnrichmentFile reads the file identified by the parameter `filePath`.
+// If that file contains nothing but the path to a `.properties` or `.json`
+// file (as the "magic" files provided by OneAgent do), the contents of that
+// other file are getting returned instead.
+func readEnrichmentFile(fsys fs.FS, filePath string) ([]byte, error) {
+	content, err := readFile(fsys, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if target, ok := enrichmentFileTarget(content); ok {
+		return readFile(fsys, target)
+	}
+	return content, nil
+}
+
+// enrichmentFileTarget returns the path of the file the given contents of
+// a "magic" file point at, if they consist of nothing but such a path
+func enrichmentFileTarget(content []byte) (string, bool) {
+	sContent := strings.TrimSpace(string(content))
+	if strings.HasSuffix(sContent, ".properties") || strings.HasSuffix(sContent, ".json") {
+		return sContent, true
+	}
+	return "", false
 }
 
 // evalHostIDFromRuxitHostID evaluates the HostID based on a configuration file
@@ -164,8 +325,8 @@ func evalHostIDFromProperties(filePath string) (string, error) {
 // If the file identified by the parameter `filePath` doesn't exist,
 // is unaccessible or doesn't contain any lines
 // an empty string is getting returned
-func evalHostIDFromRuxitHostID(filePath string) (string, error) {
-	file, err := os.Open(filePath)
+func evalHostIDFromRuxitHostID(fsys fs.FS, filePath string) (string, error) {
+	file, err := openFile(fsys, filePath)
 	if err != nil {
 		return "", err
 	}
@@ -177,5 +338,5 @@ func evalHostIDFromRuxitHostID(filePath string) (string, error) {
 		return "HOST-" + strings.TrimSpace(scanner.Text()), nil
 	}
 
-	return "", nil
+	return "", scanner.Err()
 }
@@ -0,0 +1,151 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+)
+
+// Names of the built-in host ID sources
+const (
+	// HostIDSourceMetadataFile reads the host ID from the
+	// `dt_metadata.properties` and `dt_metadata.json` files
+	HostIDSourceMetadataFile = "metadata_file"
+	// HostIDSourceRuxitHostID reads the host ID from the `ruxithost.id` files
+	HostIDSourceRuxitHostID = "ruxithost_id"
+)
+
+// HostIDSource discovers the host ID, e.g. from a file provided by OneAgent
+// or from a lookup in a CMDB. Custom sources can be registered via
+// WithHostIDSources.
+type HostIDSource interface {
+	// Name identifies the source within `sources` and is getting
+	// reported as the source of the host ID discovered by it
+	Name() string
+	// Priority determines the order the sources are getting asked in,
+	// unless configured via `sources`. Lower priorities are getting asked first.
+	Priority() int
+	// Discover returns the attributes found by the source, the host ID
+	// as `dt.entity.host`. Sources not finding a host ID return
+	// an empty map rather than an error.
+	Discover(ctx context.Context) (map[string]string, error)
+}
+
+// candidateSource is implemented by sources evaluating several candidates,
+// e.g. files, in order to report each of them
+type candidateSource interface {
+	// evalCandidates evaluates the candidates up to
+	// the first one containing a value
+	evalCandidates() []discoveryCandidate
+}
+
+// fileHostIDSource reads the host ID from the first of the given files
+// containing a value
+type fileHostIDSource struct {
+	name     string
+	priority int
+	paths    []string
+	fsys     fs.FS
+	eval     func(fsys fs.FS, filePath string) (string, error)
+}
+
+var (
+	_ HostIDSource    = (*fileHostIDSource)(nil)
+	_ candidateSource = (*fileHostIDSource)(nil)
+)
+
+func (s *fileHostIDSource) Name() string {
+	return s.name
+}
+
+func (s *fileHostIDSource) Priority() int {
+	return s.priority
+}
+
+// Discover returns the value of the first file containing one.
+// Missing and empty files aren't considered an error, only files
+// which exist but couldn't be read are getting reported.
+func (s *fileHostIDSource) Discover(_ context.Context) (map[string]string, error) {
+	var errs error
+	for _, candidate := range s.evalCandidates() {
+		switch candidate.status {
+		case candidateSelected, candidateInvalid:
+			return map[string]string{KeyEntityHost: candidate.value}, nil
+		case candidateUnreadable:
+			errs = errors.Join(errs, candidate.err)
+		}
+	}
+	return map[string]string{}, errs
+}
+
+func (s *fileHostIDSource) evalCandidates() []discoveryCandidate {
+	var candidates []discoveryCandidate
+	for _, filePath := range s.paths {
+		candidate := discoveryCandidate{path: filePath, source: s.name}
+		candidate.value, candidate.err = s.eval(s.fsys, filePath)
+		candidate.status = candidateStatusOf(candidate.value, candidate.err)
+		candidates = append(candidates, candidate)
+		if candidate.status == candidateSelected || candidate.status == candidateInvalid {
+			break
+		}
+	}
+	return candidates
+}
+
+// hostIDSources returns the built-in sources reading the files
+func (files enrichmentFiles) hostIDSources() []HostIDSource {
+	return []HostIDSource{
+		&fileHostIDSource{name: HostIDSourceMetadataFile, priority: 100, paths: files.metaData, fsys: files.fsys, eval: evalHostIDFromMetaData},
+		&fileHostIDSource{name: HostIDSourceRuxitHostID, priority: 200, paths: files.ruxitHostID, fsys: files.fsys, eval: evalHostIDFromRuxitHostID},
+	}
+}
+
+// selectHostIDSources returns the sources to ask for the host ID, in order.
+// Without any names given, all sources are getting returned, ordered by
+// their priority. Otherwise the named sources are getting returned in the
+// order given. An error is getting returned for unknown or ambiguous names.
+func selectHostIDSources(available []HostIDSource, names []string) ([]HostIDSource, error) {
+	byName := map[string]HostIDSource{}
+	for _, source := range available {
+		if _, found := byName[source.Name()]; found {
+			return nil, fmt.Errorf("host ID source %q registered more than once", source.Name())
+		}
+		byName[source.Name()] = source
+	}
+	if len(names) == 0 {
+		sources := slices.Clone(available)
+		slices.SortStableFunc(sources, func(a, b HostIDSource) int {
+			return cmp.Compare(a.Priority(), b.Priority())
+		})
+		return sources, nil
+	}
+	sources := make([]HostIDSource, 0, len(names))
+	for _, name := range names {
+		source, found := byName[name]
+		if !found {
+			return nil, fmt.Errorf("sources: unknown host ID source %q", name)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
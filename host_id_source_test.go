@@ -0,0 +1,130 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor"
+)
+
+// staticHostIDSource is a custom source returning fixed attributes
+type staticHostIDSource struct {
+	name       string
+	priority   int
+	attributes map[string]string
+	err        error
+}
+
+func (s staticHostIDSource) Name() string {
+	return s.name
+}
+
+func (s staticHostIDSource) Priority() int {
+	return s.priority
+}
+
+func (s staticHostIDSource) Discover(_ context.Context) (map[string]string, error) {
+	return s.attributes, s.err
+}
+
+func TestHostIDSources(t *testing.T) {
+	files := fstest.MapFS{
+		"var/lib/dynatrace/oneagent/agent/config/ruxithost.id": {Data: []byte("AAF98EFF909EE3F6")},
+	}
+	cmdb := staticHostIDSource{
+		name:       "cmdb",
+		priority:   300,
+		attributes: map[string]string{dynatraceprocessor.KeyEntityHost: "HOST-BBF98EFF909EE3F6", "dt.host_group.id": "webshop"},
+	}
+	failing := staticHostIDSource{name: "failing", priority: 50, err: errors.New("lookup failed")}
+
+	tests := []struct {
+		name           string
+		sources        []string
+		wantAttributes map[string]any
+		wantErr        string
+	}{
+		{
+			name:           "ordered_by_priority",
+			wantAttributes: map[string]any{dynatraceprocessor.KeyEntityHost: "HOST-AAF98EFF909EE3F6"},
+		},
+		{
+			name:    "custom_source_first",
+			sources: []string{"cmdb", dynatraceprocessor.HostIDSourceRuxitHostID},
+			wantAttributes: map[string]any{
+				dynatraceprocessor.KeyEntityHost: "HOST-BBF98EFF909EE3F6",
+				"dt.host_group.id":               "webshop",
+			},
+		},
+		{
+			name:           "failing_source_skipped",
+			sources:        []string{"failing", dynatraceprocessor.HostIDSourceMetadataFile},
+			wantAttributes: map[string]any{},
+		},
+		{
+			name:    "unknown_source",
+			sources: []string{"inventory"},
+			wantErr: `unknown host ID source "inventory"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory := dynatraceprocessor.NewFactory(
+				dynatraceprocessor.WithFileSystem(files),
+				dynatraceprocessor.WithHostIDSources(cmdb, failing))
+			cfg := factory.CreateDefaultConfig().(*dynatraceprocessor.Config)
+			cfg.Metadata = true
+			cfg.Sources = tt.sources
+			require.NoError(t, cfg.Validate())
+
+			sink := new(consumertest.TracesSink)
+			tp, err := factory.CreateTraces(context.Background(), processortest.NewNopSettings(), cfg, sink)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, tp.Shutdown(context.Background()))
+			}()
+			require.NoError(t, tp.ConsumeTraces(context.Background(), generateTraceData(nil)))
+			require.Len(t, sink.AllTraces(), 1)
+			assert.Equal(t, tt.wantAttributes, sink.AllTraces()[0].ResourceSpans().At(0).Resource().Attributes().AsRaw())
+		})
+	}
+}
+
+func TestHostIDSourcesRegisteredTwice(t *testing.T) {
+	factory := dynatraceprocessor.NewFactory(
+		dynatraceprocessor.WithFileSystem(fstest.MapFS{}),
+		dynatraceprocessor.WithHostIDSources(staticHostIDSource{name: dynatraceprocessor.HostIDSourceRuxitHostID}))
+	cfg := factory.CreateDefaultConfig().(*dynatraceprocessor.Config)
+	cfg.Metadata = true
+
+	_, err := factory.CreateTraces(context.Background(), processortest.NewNopSettings(), cfg, consumertest.NewNop())
+	assert.ErrorContains(t, err, "registered more than once")
+}
@@ -21,17 +21,20 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHostIDEvaluation(t *testing.T) {
 	tests := []struct {
 		name                    string
 		metaDataPropertiesFiles []string
+		metaDataJSONFiles       []string
 		ruxitIDFiles            []string
 		expectedHostID          string
 	}{
@@ -85,9 +88,35 @@ func TestHostIDEvaluation(t *testing.T) {
 			ruxitIDFiles:   []string{},
 			expectedHostID: "HOST-AAF98EFF909EE3F6",
 		},
+		{
+			name:              "valid_json_metadata_file",
+			metaDataJSONFiles: []string{`{"dt.entity.host": "HOST-AAF98EFF909EE3F6", "dt.host_group.id": "production"}`},
+			ruxitIDFiles:      []string{},
+			expectedHostID:    "HOST-AAF98EFF909EE3F6",
+		},
+		{
+			name:              "invalid_json_metadata_file",
+			metaDataJSONFiles: []string{`{"dt.entity.host": "HOST-AAF98EFF909EE3F6"`},
+			ruxitIDFiles:      []string{},
+			expectedHostID:    "",
+		},
+		{
+			name:                    "properties_before_json_metadata_file",
+			metaDataPropertiesFiles: []string{`dt.entity.host=HOST-AAF98EFF909EE3F6`},
+			metaDataJSONFiles:       []string{`{"dt.entity.host": "HOST-BBF98EFF909EE3F6"}`},
+			ruxitIDFiles:            []string{},
+			expectedHostID:          "HOST-AAF98EFF909EE3F6",
+		},
+		{
+			name:              "json_metadata_file_before_ruxit_id_file",
+			metaDataJSONFiles: []string{`{"dt.entity.host": "HOST-BBF98EFF909EE3F6"}`},
+			ruxitIDFiles:      []string{`AAF98EFF909EE3F6`},
+			expectedHostID:    "HOST-BBF98EFF909EE3F6",
+		},
 		{
 			name:                    "non_existent_files",
 			metaDataPropertiesFiles: []string{"nil"},
+			metaDataJSONFiles:       []string{"nil"},
 			ruxitIDFiles:            []string{"nil"},
 			expectedHostID:          "",
 		},
@@ -121,6 +150,20 @@ func TestHostIDEvaluation(t *testing.T) {
 				}
 			}
 			ctx := context.WithValue(context.Background(), dynatraceprocessor.CtxKeyMetaDataPropertiesFilePaths, metaDataPropertiesFilePaths)
+			metaDataJSONFilePaths := []string{}
+			for _, content := range tt.metaDataJSONFiles {
+				if content != "nil" {
+					tempFile, err := createConfigFile(content)
+					if err != nil {
+						return
+					}
+					defer os.Remove(tempFile.Name())
+					metaDataJSONFilePaths = append(metaDataJSONFilePaths, tempFile.Name())
+				} else {
+					metaDataJSONFilePaths = append(metaDataJSONFilePaths, uuid.NewString())
+				}
+			}
+			ctx = context.WithValue(ctx, dynatraceprocessor.CtxKeyMetaDataJSONFilePaths, metaDataJSONFilePaths)
 			ruxitHostIDFilePaths := []string{}
 			for _, content := range tt.ruxitIDFiles {
 				if content != "nil" {
@@ -142,3 +185,41 @@ func TestHostIDEvaluation(t *testing.T) {
 		})
 	}
 }
+
+func TestHostIDEvaluationFromMagicFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctxKey  dynatraceprocessor.CtxKey
+		ext     string
+		content string
+	}{
+		{
+			name:    "properties",
+			ctxKey:  dynatraceprocessor.CtxKeyMetaDataPropertiesFilePaths,
+			ext:     ".properties",
+			content: "dt.entity.host=HOST-AAF98EFF909EE3F6",
+		},
+		{
+			name:    "json",
+			ctxKey:  dynatraceprocessor.CtxKeyMetaDataJSONFilePaths,
+			ext:     ".json",
+			content: `{"dt.entity.host": "HOST-AAF98EFF909EE3F6"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			metaDataFilePath := filepath.Join(dir, "dt_metadata"+tt.ext)
+			require.NoError(t, os.WriteFile(metaDataFilePath, []byte(tt.content), 0o600))
+			magicFilePath := filepath.Join(dir, "dt_metadata_e617c525669e072eebe3d0f08212e8f2"+tt.ext)
+			require.NoError(t, os.WriteFile(magicFilePath, []byte(metaDataFilePath+"\n"), 0o600))
+
+			// either key replaces all default metadata files
+			ctx := context.WithValue(context.Background(), tt.ctxKey, []string{magicFilePath})
+			ctx = context.WithValue(ctx, dynatraceprocessor.CtxKeyRuxitHostIDFilePaths, []string{})
+
+			assert.Equal(t, "HOST-AAF98EFF909EE3F6", dynatraceprocessor.EvalHostID(ctx))
+		})
+	}
+}
@@ -0,0 +1,88 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"io/fs"
+	"runtime"
+)
+
+const (
+	KeyHostID   = "host.id"
+	KeyHostName = "host.name"
+	KeyOSType   = "os.type"
+)
+
+// dmiProductUUIDFilePath is the location of the DMI product UUID
+const dmiProductUUIDFilePath = "/sys/class/dmi/id/product_uuid"
+
+// machineIDFilePaths returns the locations of the machine ID,
+// in the order they are getting looked at
+func machineIDFilePaths() []string {
+	return []string{
+		"/etc/machine-id",
+		"/var/lib/dbus/machine-id",
+	}
+}
+
+// osTypes maps the values of `runtime.GOOS` to the values
+// of `os.type` as defined by the semantic conventions,
+// in case they differ
+var osTypes = map[string]string{
+	"dragonfly": "dragonflybsd",
+	"zos":       "z_os",
+}
+
+// evalHostIdentity evaluates the identity of the current host without
+// relying on OneAgent, based on the sources enabled in the given config.
+// `host.id` is getting read from the machine ID, or if unavailable, from
+// the DMI product UUID. `host.name` is the hostname looked up via the
+// given function. Sources which are unavailable or unreadable are getting
+// skipped.
+func evalHostIdentity(fsys fs.FS, hostname func() (string, error), cfg HostIdentityConfig) Metadata {
+	metadata := Metadata{}
+	if cfg.MachineID {
+		for _, machineIDFilePath := range machineIDFilePaths() {
+			if value, err := evalFirstLine(fsys, machineIDFilePath); err == nil && len(value) > 0 {
+				metadata[KeyHostID] = value
+				break
+			}
+		}
+	}
+	if _, found := metadata[KeyHostID]; !found && cfg.DMIProductUUID {
+		if value, err := evalFirstLine(fsys, dmiProductUUIDFilePath); err == nil && len(value) > 0 {
+			metadata[KeyHostID] = value
+		}
+	}
+	if cfg.Hostname {
+		if value, err := hostname(); err == nil && len(value) > 0 {
+			metadata[KeyHostName] = value
+		}
+	}
+	if cfg.OSType {
+		metadata[KeyOSType] = osType(runtime.GOOS)
+	}
+	return metadata
+}
+
+func osType(goos string) string {
+	if value, found := osTypes[goos]; found {
+		return value
+	}
+	return goos
+}
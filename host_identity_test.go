@@ -0,0 +1,123 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func TestHostIdentity(t *testing.T) {
+	tests := []struct {
+		name             string
+		config           HostIdentityConfig
+		machineID        string
+		dmiProductUUID   string
+		hostnameErr      error
+		expectedMetadata Metadata
+	}{
+		{
+			name:             "disabled",
+			machineID:        "4c4c4544004a4d1080345ac04f563533",
+			expectedMetadata: Metadata{},
+		},
+		{
+			name:           "all_sources",
+			config:         HostIdentityConfig{MachineID: true, DMIProductUUID: true, Hostname: true, OSType: true},
+			machineID:      "4c4c4544004a4d1080345ac04f563533\n",
+			dmiProductUUID: "EC2B5A6C-4E5F-1A2B-3C4D-5E6F7A8B9C0D",
+			expectedMetadata: Metadata{
+				KeyHostID:   "4c4c4544004a4d1080345ac04f563533",
+				KeyHostName: "collector-01",
+				KeyOSType:   osType(runtime.GOOS),
+			},
+		},
+		{
+			name:           "dmi_product_uuid_as_fallback",
+			config:         HostIdentityConfig{MachineID: true, DMIProductUUID: true},
+			dmiProductUUID: "EC2B5A6C-4E5F-1A2B-3C4D-5E6F7A8B9C0D",
+			expectedMetadata: Metadata{
+				KeyHostID: "EC2B5A6C-4E5F-1A2B-3C4D-5E6F7A8B9C0D",
+			},
+		},
+		{
+			name:             "unavailable_sources",
+			config:           HostIdentityConfig{MachineID: true, DMIProductUUID: true, Hostname: true},
+			hostnameErr:      errors.New("no hostname"),
+			expectedMetadata: Metadata{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			if tt.machineID != "" {
+				fsys["var/lib/dbus/machine-id"] = &fstest.MapFile{Data: []byte(tt.machineID)}
+			}
+			if tt.dmiProductUUID != "" {
+				fsys["sys/class/dmi/id/product_uuid"] = &fstest.MapFile{Data: []byte(tt.dmiProductUUID)}
+			}
+			hostname := func() (string, error) {
+				if tt.hostnameErr != nil {
+					return "", tt.hostnameErr
+				}
+				return "collector-01", nil
+			}
+
+			assert.Equal(t, tt.expectedMetadata, evalHostIdentity(fsys, hostname, tt.config))
+		})
+	}
+}
+
+func TestHostIdentityIsFallback(t *testing.T) {
+	f := &factory{
+		fsys: fstest.MapFS{
+			"etc/machine-id": &fstest.MapFile{Data: []byte("4c4c4544004a4d1080345ac04f563533")},
+		},
+		hostname: func() (string, error) {
+			return "collector-01", nil
+		},
+	}
+	cfg := &Config{
+		Metadata:         true,
+		MetaDataFiles:    []string{},
+		RuxitHostIDFiles: []string{},
+		HostIdentity:     HostIdentityConfig{MachineID: true, Hostname: true},
+	}
+
+	proc, err := newDynatraceProcessor(context.Background(), processortest.NewNopSettings(), cfg, f)
+	require.NoError(t, err)
+	assert.Equal(t, "", proc.engine.current.Load().hostID)
+	assert.Equal(t, "4c4c4544004a4d1080345ac04f563533", proc.engine.current.Load().attributes[KeyHostID])
+	assert.Equal(t, "collector-01", proc.engine.current.Load().attributes[KeyHostName])
+	require.NoError(t, proc.shutdown(context.Background()))
+
+	ctx := context.WithValue(context.Background(), MetaDataKeyDTEntityHost, "HOST-AAF98EFF909EE3F6")
+	proc, err = newDynatraceProcessor(ctx, processortest.NewNopSettings(), cfg, f)
+	require.NoError(t, err)
+	assert.Equal(t, "HOST-AAF98EFF909EE3F6", proc.engine.current.Load().hostID)
+	assert.NotContains(t, proc.engine.current.Load().attributes, KeyHostID)
+	require.NoError(t, proc.shutdown(ctx))
+}
@@ -0,0 +1,84 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"bufio"
+	"io/fs"
+	"strings"
+)
+
+const (
+	KeyK8sClusterUID                = "k8s.cluster.uid"
+	KeyK8sNamespaceName             = "k8s.namespace.name"
+	KeyDTKubernetesWorkloadKind     = "dt.kubernetes.workload.kind"
+	KeyDTKubernetesWorkloadName     = "dt.kubernetes.workload.name"
+	DefaultKubernetesNamespaceFile  = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	defaultKubernetesDownwardAPIDir = "/etc/podinfo/"
+)
+
+// DefaultKubernetesFiles returns the downward API mounted files
+// evaluated by default, per resource attribute
+func DefaultKubernetesFiles() map[string]string {
+	return map[string]string{
+		KeyK8sClusterUID:            defaultKubernetesDownwardAPIDir + KeyK8sClusterUID,
+		KeyK8sNamespaceName:         defaultKubernetesDownwardAPIDir + KeyK8sNamespaceName,
+		KeyDTKubernetesWorkloadKind: defaultKubernetesDownwardAPIDir + KeyDTKubernetesWorkloadKind,
+		KeyDTKubernetesWorkloadName: defaultKubernetesDownwardAPIDir + KeyDTKubernetesWorkloadName,
+	}
+}
+
+// evalMetadataFromKubernetes evaluates the resource attributes
+// based on files mounted into the pod via the downward API.
+// The namespace falls back to the namespace of the service account,
+// if it isn't available via the downward API.
+// No live Kubernetes API server is getting contacted.
+// Files which don't exist or are empty are getting skipped.
+func evalMetadataFromKubernetes(fsys fs.FS, files map[string]string, namespaceFilePath string) Metadata {
+	metadata := Metadata{}
+	for key, filePath := range files {
+		if filePath == "" {
+			continue
+		}
+		if value, err := evalFirstLine(fsys, filePath); err == nil && len(value) > 0 {
+			metadata[key] = value
+		}
+	}
+	if _, found := metadata[KeyK8sNamespaceName]; !found && namespaceFilePath != "" {
+		if value, err := evalFirstLine(fsys, namespaceFilePath); err == nil && len(value) > 0 {
+			metadata[KeyK8sNamespaceName] = value
+		}
+	}
+	return metadata
+}
+
+// evalFirstLine returns the trimmed first line of the file
+// identified by the parameter `filePath`
+func evalFirstLine(fsys fs.FS, filePath string) (string, error) {
+	file, err := openFile(fsys, filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if scanner.Scan() {
+		return strings.TrimSpace(scanner.Text()), nil
+	}
+	return "", scanner.Err()
+}
@@ -0,0 +1,103 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/pdatatest/pmetrictest"
+)
+
+func TestDynatraceProcessorKubernetes(t *testing.T) {
+	tests := []struct {
+		name           string
+		files          map[string]string
+		wantAttributes map[string]string
+	}{
+		{
+			name: "downward_api",
+			files: map[string]string{
+				dynatraceprocessor.KeyK8sClusterUID:            "d5f2c5b1-6e0c-4b0a-9b2e-1f4c7f4e8a11\n",
+				dynatraceprocessor.KeyK8sNamespaceName:         "observability",
+				dynatraceprocessor.KeyDTKubernetesWorkloadKind: "daemonset",
+				dynatraceprocessor.KeyDTKubernetesWorkloadName: "otel-collector",
+			},
+			wantAttributes: map[string]string{
+				dynatraceprocessor.KeyK8sClusterUID:            "d5f2c5b1-6e0c-4b0a-9b2e-1f4c7f4e8a11",
+				dynatraceprocessor.KeyK8sNamespaceName:         "observability",
+				dynatraceprocessor.KeyDTKubernetesWorkloadKind: "daemonset",
+				dynatraceprocessor.KeyDTKubernetesWorkloadName: "otel-collector",
+			},
+		},
+		{
+			name: "namespace_of_service_account",
+			files: map[string]string{
+				dynatraceprocessor.KeyDTKubernetesWorkloadName: "otel-collector",
+				dynatraceprocessor.KeyDTKubernetesWorkloadKind: "",
+			},
+			wantAttributes: map[string]string{
+				dynatraceprocessor.KeyK8sNamespaceName:         "default",
+				dynatraceprocessor.KeyDTKubernetesWorkloadName: "otel-collector",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			namespaceFilePath := filepath.Join(dir, "namespace")
+			require.NoError(t, os.WriteFile(namespaceFilePath, []byte("default"), 0o600))
+
+			files := map[string]string{}
+			for key := range dynatraceprocessor.DefaultKubernetesFiles() {
+				files[key] = filepath.Join(dir, "missing", key)
+			}
+			for key, content := range tt.files {
+				files[key] = filepath.Join(dir, key)
+				require.NoError(t, os.WriteFile(files[key], []byte(content), 0o600))
+			}
+
+			cfg := &dynatraceprocessor.Config{
+				MetaDataFiles:    []string{},
+				RuxitHostIDFiles: []string{},
+				Kubernetes: dynatraceprocessor.KubernetesConfig{
+					Enabled:       true,
+					Files:         files,
+					NamespaceFile: namespaceFilePath,
+				},
+			}
+			require.NoError(t, cfg.Validate())
+
+			sink := new(consumertest.MetricsSink)
+			mp, err := dynatraceprocessor.NewFactory().CreateMetrics(context.Background(), processortest.NewNopSettings(), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, mp.ConsumeMetrics(context.Background(), generateMetricData(nil)))
+			require.Len(t, sink.AllMetrics(), 1)
+			assert.NoError(t, pmetrictest.CompareMetrics(generateMetricData(tt.wantAttributes), sink.AllMetrics()[0]))
+		})
+	}
+}
@@ -0,0 +1,146 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// KeyTruncated is the attribute flagging log records
+// which got truncated in order to comply with the limits
+const KeyTruncated = "dt.truncated"
+
+// logLimits holds the limits log records are getting truncated to
+type logLimits struct {
+	maxContentLength        int
+	maxAttributes           int
+	maxAttributeValueLength int
+}
+
+// limitLogs truncates the body, the attributes and the attribute values
+// of log records exceeding the configured limits and flags them with
+// the attribute `dt.truncated`
+func (rp *dynatraceProcessor) limitLogs(ctx context.Context, ld plog.Logs) {
+	var truncated int64
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		sls := rls.At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			lrs := sls.At(j).LogRecords()
+			for k := 0; k < lrs.Len(); k++ {
+				if rp.logLimits.limit(lrs.At(k)) {
+					truncated++
+				}
+			}
+		}
+	}
+	if truncated > 0 {
+		rp.telemetry.truncatedLogRecords.Add(ctx, truncated)
+	}
+}
+
+// protectedLogAttributes lists the attributes set by the processor itself,
+// which are getting kept in favor of any other attribute when the number
+// of attributes exceeds the limit
+var protectedLogAttributes = map[string]bool{
+	KeyLogLevel:     true,
+	KeyTraceID:      true,
+	KeySpanID:       true,
+	KeyTraceSampled: true,
+}
+
+// limit truncates the given log record.
+// Returns true if anything got truncated.
+func (l logLimits) limit(lr plog.LogRecord) bool {
+	truncated := false
+	if body := lr.Body(); body.Type() == pcommon.ValueTypeStr && l.maxContentLength > 0 {
+		if content := truncateBytes(body.Str(), l.maxContentLength); len(content) < len(body.Str()) {
+			body.SetStr(content)
+			truncated = true
+		}
+	}
+
+	attrs := lr.Attributes()
+	if l.maxAttributeValueLength > 0 {
+		attrs.Range(func(_ string, v pcommon.Value) bool {
+			if v.Type() != pcommon.ValueTypeStr {
+				return true
+			}
+			if value := truncateBytes(v.Str(), l.maxAttributeValueLength); len(value) < len(v.Str()) {
+				v.SetStr(value)
+				truncated = true
+			}
+			return true
+		})
+	}
+	if l.maxAttributes > 0 {
+		_, flagged := attrs.Get(KeyTruncated)
+		// the flag needs a slot of its own, unless it's present already
+		if attrs.Len() > l.maxAttributes || (truncated && !flagged && attrs.Len() == l.maxAttributes) {
+			limitAttributes(attrs, l.maxAttributes-1)
+			truncated = true
+		}
+	}
+
+	if truncated {
+		attrs.PutBool(KeyTruncated, true)
+	}
+	return truncated
+}
+
+// limitAttributes removes attributes until at most `limit` of them remain,
+// not counting `dt.truncated`. The attributes set by the processor itself
+// are getting kept first, then the other ones in their order.
+func limitAttributes(attrs pcommon.Map, limit int) {
+	protected := 0
+	attrs.Range(func(k string, _ pcommon.Value) bool {
+		if protectedLogAttributes[k] {
+			protected++
+		}
+		return true
+	})
+	others := max(limit-protected, 0)
+	keptProtected, keptOthers := 0, 0
+	attrs.RemoveIf(func(k string, _ pcommon.Value) bool {
+		if k == KeyTruncated {
+			return false
+		}
+		if protectedLogAttributes[k] {
+			keptProtected++
+			return keptProtected > limit
+		}
+		keptOthers++
+		return keptOthers > others
+	})
+}
+
+// truncateBytes shortens the given string to at most `limit` bytes
+// without splitting up multi-byte characters
+func truncateBytes(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
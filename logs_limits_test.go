@@ -0,0 +1,140 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestTruncateBytes(t *testing.T) {
+	assert.Equal(t, "abc", truncateBytes("abc", 5))
+	assert.Equal(t, "abc", truncateBytes("abcdef", 3))
+	// `ä` takes two bytes and must not be split up
+	assert.Equal(t, "a", truncateBytes("aäb", 2))
+	assert.Equal(t, "aä", truncateBytes("aäb", 3))
+}
+
+func TestLogLimits(t *testing.T) {
+	limits := logLimits{maxContentLength: 10, maxAttributes: 3, maxAttributeValueLength: 5}
+
+	tests := []struct {
+		name          string
+		body          string
+		attributes    map[string]any
+		expectedBody  string
+		expectedAttrs map[string]any
+	}{
+		{
+			name:          "within_limits",
+			body:          "short",
+			attributes:    map[string]any{"a": "value"},
+			expectedBody:  "short",
+			expectedAttrs: map[string]any{"a": "value"},
+		},
+		{
+			name:          "content_too_long",
+			body:          strings.Repeat("x", 20),
+			expectedBody:  strings.Repeat("x", 10),
+			expectedAttrs: map[string]any{KeyTruncated: true},
+		},
+		{
+			name:          "attribute_value_too_long",
+			body:          "short",
+			attributes:    map[string]any{"a": "long value", "b": int64(1234567)},
+			expectedBody:  "short",
+			expectedAttrs: map[string]any{"a": "long ", "b": int64(1234567), KeyTruncated: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lr := plog.NewLogRecord()
+			lr.Body().SetStr(tt.body)
+			require.NoError(t, lr.Attributes().FromRaw(tt.attributes))
+			limits.limit(lr)
+			assert.Equal(t, tt.expectedBody, lr.Body().Str())
+			assert.Equal(t, tt.expectedAttrs, lr.Attributes().AsRaw())
+		})
+	}
+}
+
+func TestLogLimitsAttributeCount(t *testing.T) {
+	lr := plog.NewLogRecord()
+	lr.Attributes().PutStr("a", "1")
+	lr.Attributes().PutStr("b", "2")
+	lr.Attributes().PutStr("c", "3")
+	assert.True(t, logLimits{maxAttributes: 2}.limit(lr))
+	// one slot is reserved for the flag
+	assert.Equal(t, map[string]any{"a": "1", KeyTruncated: true}, lr.Attributes().AsRaw())
+}
+
+func TestLogLimitsAttributeCountFlagSlot(t *testing.T) {
+	lr := plog.NewLogRecord()
+	lr.Body().SetStr("too long")
+	lr.Attributes().PutStr("a", "1")
+	lr.Attributes().PutStr("b", "2")
+	assert.True(t, logLimits{maxContentLength: 3, maxAttributes: 2}.limit(lr))
+	assert.Equal(t, map[string]any{"a": "1", KeyTruncated: true}, lr.Attributes().AsRaw())
+}
+
+func TestLogLimitsAttributeCountKeepsOwnAttributes(t *testing.T) {
+	lr := plog.NewLogRecord()
+	lr.Attributes().PutStr("a", "1")
+	lr.Attributes().PutStr("b", "2")
+	lr.Attributes().PutStr("c", "3")
+	lr.Attributes().PutStr(KeyLogLevel, "WARN")
+	lr.Attributes().PutStr(KeyTraceID, "5b8efff798038103d269b633813fc60c")
+	lr.Attributes().PutStr(KeySpanID, "eee19b7ec3c1b174")
+	assert.True(t, logLimits{maxAttributes: 5}.limit(lr))
+	assert.Equal(t, map[string]any{
+		"a":          "1",
+		KeyLogLevel:  "WARN",
+		KeyTraceID:   "5b8efff798038103d269b633813fc60c",
+		KeySpanID:    "eee19b7ec3c1b174",
+		KeyTruncated: true,
+	}, lr.Attributes().AsRaw())
+}
+
+func TestLimitLogs(t *testing.T) {
+	set, telemetry := newTestTelemetry()
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetaDataFiles = []string{}
+	cfg.RuxitHostIDFiles = []string{}
+	cfg.LogLimits.Enabled = true
+	cfg.LogLimits.MaxContentLength = 4
+	proc, err := newDynatraceProcessor(context.Background(), set, cfg, nil)
+	require.NoError(t, err)
+
+	ld := plog.NewLogs()
+	lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	lrs.AppendEmpty().Body().SetStr("too long")
+	lrs.AppendEmpty().Body().SetStr("ok")
+	_, err = proc.processLogs(context.Background(), ld)
+	require.NoError(t, err)
+
+	assert.Equal(t, "too ", lrs.At(0).Body().Str())
+	assert.Equal(t, "ok", lrs.At(1).Body().Str())
+	_, found := lrs.At(1).Attributes().Get(KeyTruncated)
+	assert.False(t, found)
+	assert.Equal(t, int64(1), telemetry.sum(t, "processor_dynatrace_truncated_log_records"))
+}
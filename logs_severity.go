@@ -0,0 +1,249 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// KeyLogLevel is the attribute Dynatrace derives the log level from
+const KeyLogLevel = "loglevel"
+
+// KeySyslogPriority is the attribute containing the syslog priority
+// (facility * 8 + severity) of a log record
+const KeySyslogPriority = "syslog.priority"
+
+// DefaultSeverityAttributes returns the attributes looked at
+// for the severity of a log record, in that order
+func DefaultSeverityAttributes() []string {
+	return []string{"level", "severity", "log.level", KeyLogLevel, "syslog.severity", KeySyslogPriority}
+}
+
+// DefaultSeverityBodyPatterns returns the patterns the body of a log record
+// is getting matched against, in that order. The first capture group is
+// expected to contain the level.
+// Level names are only getting recognized at the beginning of the body,
+// so that e.g. `request completed, no error` isn't considered an error.
+func DefaultSeverityBodyPatterns() []string {
+	return []string{
+		// syslog priority, e.g. `<34>Oct 11 22:14:15 ...`
+		`^<(\d{1,3})>`,
+		// leading level, e.g. `ERROR: ...` or `[warn] ...`
+		`(?i)^\s*\[?(trace|debug|info|notice|warn|warning|error|err|severe|crit|critical|alert|emerg|emergency|fatal|panic)\]?(?:[:\s]|$)`,
+	}
+}
+
+// severityLevel maps a level to its severity number and Dynatrace log level
+type severityLevel struct {
+	text     string
+	number   plog.SeverityNumber
+	logLevel string
+}
+
+// severityLevels maps the lower case names of known levels
+var severityLevels = map[string]severityLevel{
+	"trace":     {"TRACE", plog.SeverityNumberTrace, "DEBUG"},
+	"debug":     {"DEBUG", plog.SeverityNumberDebug, "DEBUG"},
+	"info":      {"INFO", plog.SeverityNumberInfo, "INFO"},
+	"notice":    {"NOTICE", plog.SeverityNumberInfo2, "NOTICE"},
+	"warn":      {"WARN", plog.SeverityNumberWarn, "WARN"},
+	"warning":   {"WARNING", plog.SeverityNumberWarn, "WARN"},
+	"error":     {"ERROR", plog.SeverityNumberError, "ERROR"},
+	"err":       {"ERR", plog.SeverityNumberError, "ERROR"},
+	"severe":    {"SEVERE", plog.SeverityNumberFatal, "SEVERE"},
+	"fatal":     {"FATAL", plog.SeverityNumberFatal, "SEVERE"},
+	"panic":     {"PANIC", plog.SeverityNumberFatal, "SEVERE"},
+	"crit":      {"CRIT", plog.SeverityNumberFatal2, "CRITICAL"},
+	"critical":  {"CRITICAL", plog.SeverityNumberFatal2, "CRITICAL"},
+	"alert":     {"ALERT", plog.SeverityNumberFatal3, "ALERT"},
+	"emerg":     {"EMERG", plog.SeverityNumberFatal4, "EMERGENCY"},
+	"emergency": {"EMERGENCY", plog.SeverityNumberFatal4, "EMERGENCY"},
+}
+
+// syslogSeverities lists the names of the numeric syslog severities
+var syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// maxSyslogPriority is the highest syslog priority (facility * 8 + severity)
+const maxSyslogPriority = 191
+
+// maxSyslogSeverity is the highest syslog severity
+const maxSyslogSeverity = 7
+
+// severityInference determines the severity of log records
+// without a severity number
+type severityInference struct {
+	attributes   []string
+	bodyPatterns []*regexp.Regexp
+}
+
+func newSeverityInference(cfg SeverityConfig) (*severityInference, error) {
+	inference := &severityInference{attributes: cfg.Attributes}
+	for _, pattern := range cfg.BodyPatterns {
+		re, err := compileSeverityPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		inference.bodyPatterns = append(inference.bodyPatterns, re)
+	}
+	return inference, nil
+}
+
+// compileSeverityPattern compiles the given pattern
+// and ensures it contains a capture group
+func compileSeverityPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("pattern %q doesn't contain a capture group", pattern)
+	}
+	return re, nil
+}
+
+// inferSeverity infers the severity of all log records of the given logs
+func (rp *dynatraceProcessor) inferSeverity(ld plog.Logs) {
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		sls := rls.At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			lrs := sls.At(j).LogRecords()
+			for k := 0; k < lrs.Len(); k++ {
+				rp.severity.apply(lrs.At(k))
+			}
+		}
+	}
+}
+
+// apply infers the severity of the given log record, if it has no
+// severity number yet, and aligns the `loglevel` attribute with it.
+// The severity text is only getting kept if the level got inferred from it,
+// otherwise it's getting replaced with the name of the inferred level.
+func (si *severityInference) apply(lr plog.LogRecord) {
+	if lr.SeverityNumber() == plog.SeverityNumberUnspecified {
+		if level, found := parseSeverityText(lr.SeverityText(), false); found {
+			lr.SetSeverityNumber(level.number)
+			lr.Attributes().PutStr(KeyLogLevel, level.logLevel)
+			return
+		}
+		level, found := si.infer(lr)
+		if !found {
+			return
+		}
+		lr.SetSeverityNumber(level.number)
+		lr.SetSeverityText(level.text)
+		lr.Attributes().PutStr(KeyLogLevel, level.logLevel)
+		return
+	}
+	lr.Attributes().PutStr(KeyLogLevel, logLevelOf(lr.SeverityNumber()))
+}
+
+// infer determines the level based on the configured attributes
+// and, if none of them contains a known level, on the body.
+// Numbers are getting treated as syslog priority if found in the body
+// or in `syslog.priority`, as syslog severity otherwise.
+func (si *severityInference) infer(lr plog.LogRecord) (severityLevel, bool) {
+	for _, key := range si.attributes {
+		if value, found := lr.Attributes().Get(key); found {
+			if level, found := parseSeverityLevel(value, key == KeySyslogPriority); found {
+				return level, true
+			}
+		}
+	}
+	if lr.Body().Type() != pcommon.ValueTypeStr {
+		return severityLevel{}, false
+	}
+	for _, re := range si.bodyPatterns {
+		if match := re.FindStringSubmatch(lr.Body().Str()); match != nil {
+			if level, found := parseSeverityText(match[1], true); found {
+				return level, true
+			}
+		}
+	}
+	return severityLevel{}, false
+}
+
+// parseSeverityLevel parses the level contained in an attribute value.
+// Numbers are getting treated as syslog priority if `priority` is true,
+// as syslog severity otherwise.
+func parseSeverityLevel(value pcommon.Value, priority bool) (severityLevel, bool) {
+	switch value.Type() {
+	case pcommon.ValueTypeStr:
+		return parseSeverityText(value.Str(), priority)
+	case pcommon.ValueTypeInt:
+		return syslogSeverityLevel(value.Int(), priority)
+	}
+	return severityLevel{}, false
+}
+
+// parseSeverityText parses a level name or a number,
+// the same way `parseSeverityLevel` does
+func parseSeverityText(text string, priority bool) (severityLevel, bool) {
+	text = strings.TrimSpace(text)
+	if number, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return syslogSeverityLevel(number, priority)
+	}
+	level, found := severityLevels[strings.ToLower(text)]
+	return level, found
+}
+
+// syslogSeverityLevel maps a syslog severity (0-7) or, if `priority` is
+// true, a syslog priority (facility * 8 + severity) to the corresponding
+// level. Other numbers, e.g. the level `40` used by pino or bunyan,
+// aren't getting recognized.
+func syslogSeverityLevel(number int64, priority bool) (severityLevel, bool) {
+	limit := int64(maxSyslogSeverity)
+	if priority {
+		limit = maxSyslogPriority
+	}
+	if number < 0 || number > limit {
+		return severityLevel{}, false
+	}
+	return severityLevels[syslogSeverities[number%8]], true
+}
+
+// logLevelOf maps a severity number to the corresponding Dynatrace log level
+func logLevelOf(number plog.SeverityNumber) string {
+	switch {
+	case number >= plog.SeverityNumberFatal4:
+		return "EMERGENCY"
+	case number == plog.SeverityNumberFatal3:
+		return "ALERT"
+	case number == plog.SeverityNumberFatal2:
+		return "CRITICAL"
+	case number == plog.SeverityNumberFatal:
+		return "SEVERE"
+	case number >= plog.SeverityNumberError:
+		return "ERROR"
+	case number >= plog.SeverityNumberWarn:
+		return "WARN"
+	case number >= plog.SeverityNumberInfo2:
+		return "NOTICE"
+	case number == plog.SeverityNumberInfo:
+		return "INFO"
+	case number >= plog.SeverityNumberTrace:
+		return "DEBUG"
+	}
+	return "NONE"
+}
@@ -0,0 +1,205 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestSeverityInference(t *testing.T) {
+	inference, err := newSeverityInference(SeverityConfig{
+		Attributes:   DefaultSeverityAttributes(),
+		BodyPatterns: DefaultSeverityBodyPatterns(),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name             string
+		body             string
+		attributes       map[string]any
+		severityNumber   plog.SeverityNumber
+		severityText     string
+		expectedNumber   plog.SeverityNumber
+		expectedText     string
+		expectedLogLevel string
+	}{
+		{
+			name:             "attribute",
+			attributes:       map[string]any{"level": "warning"},
+			expectedNumber:   plog.SeverityNumberWarn,
+			expectedText:     "WARNING",
+			expectedLogLevel: "WARN",
+		},
+		{
+			name:             "attribute_order",
+			attributes:       map[string]any{"level": "Info", "severity": "error"},
+			expectedNumber:   plog.SeverityNumberInfo,
+			expectedText:     "INFO",
+			expectedLogLevel: "INFO",
+		},
+		{
+			name:             "unknown_attribute_value",
+			body:             "ERROR connection refused",
+			attributes:       map[string]any{"level": "verbose"},
+			expectedNumber:   plog.SeverityNumberError,
+			expectedText:     "ERROR",
+			expectedLogLevel: "ERROR",
+		},
+		{
+			name:             "numeric_syslog_severity",
+			attributes:       map[string]any{"syslog.severity": int64(2)},
+			expectedNumber:   plog.SeverityNumberFatal2,
+			expectedText:     "CRIT",
+			expectedLogLevel: "CRITICAL",
+		},
+		{
+			name:           "numeric_level_is_no_syslog_priority",
+			attributes:     map[string]any{"level": int64(40)},
+			expectedNumber: plog.SeverityNumberUnspecified,
+		},
+		{
+			name:             "syslog_priority_attribute",
+			attributes:       map[string]any{"syslog.priority": int64(34)},
+			expectedNumber:   plog.SeverityNumberFatal2,
+			expectedText:     "CRIT",
+			expectedLogLevel: "CRITICAL",
+		},
+		{
+			name:             "syslog_priority_in_body",
+			body:             "<34>Oct 11 22:14:15 mymachine su: 'su root' failed",
+			expectedNumber:   plog.SeverityNumberFatal2,
+			expectedText:     "CRIT",
+			expectedLogLevel: "CRITICAL",
+		},
+		{
+			name:             "body",
+			body:             "fatal: unable to access repository",
+			expectedNumber:   plog.SeverityNumberFatal,
+			expectedText:     "FATAL",
+			expectedLogLevel: "SEVERE",
+		},
+		{
+			name:             "bracketed_body",
+			body:             "[warn] disk almost full",
+			expectedNumber:   plog.SeverityNumberWarn,
+			expectedText:     "WARN",
+			expectedLogLevel: "WARN",
+		},
+		{
+			name:           "level_within_body",
+			body:           "request completed, no error",
+			expectedNumber: plog.SeverityNumberUnspecified,
+		},
+		{
+			name:             "unknown_severity_text",
+			body:             "[W] disk almost full",
+			attributes:       map[string]any{"level": "warn"},
+			severityText:     "W",
+			expectedNumber:   plog.SeverityNumberWarn,
+			expectedText:     "WARN",
+			expectedLogLevel: "WARN",
+		},
+		{
+			name:             "severity_text_only",
+			body:             "connection refused",
+			severityText:     "Error",
+			expectedNumber:   plog.SeverityNumberError,
+			expectedText:     "Error",
+			expectedLogLevel: "ERROR",
+		},
+		{
+			name:             "severity_text_takes_precedence",
+			attributes:       map[string]any{"level": "info"},
+			severityText:     "ERROR",
+			expectedNumber:   plog.SeverityNumberError,
+			expectedText:     "ERROR",
+			expectedLogLevel: "ERROR",
+		},
+		{
+			name:             "existing_severity_number",
+			body:             "ERROR but reported as debug",
+			severityNumber:   plog.SeverityNumberDebug,
+			expectedNumber:   plog.SeverityNumberDebug,
+			expectedLogLevel: "DEBUG",
+		},
+		{
+			name:           "unknown",
+			body:           "information only",
+			expectedNumber: plog.SeverityNumberUnspecified,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lr := plog.NewLogRecord()
+			lr.Body().SetStr(tt.body)
+			require.NoError(t, lr.Attributes().FromRaw(tt.attributes))
+			lr.SetSeverityNumber(tt.severityNumber)
+			lr.SetSeverityText(tt.severityText)
+
+			inference.apply(lr)
+
+			assert.Equal(t, tt.expectedNumber, lr.SeverityNumber())
+			assert.Equal(t, tt.expectedText, lr.SeverityText())
+			logLevel, found := lr.Attributes().Get(KeyLogLevel)
+			if tt.expectedLogLevel == "" {
+				assert.False(t, found)
+			} else {
+				require.True(t, found)
+				assert.Equal(t, tt.expectedLogLevel, logLevel.Str())
+			}
+		})
+	}
+}
+
+func TestLogLevelOf(t *testing.T) {
+	// every level is expected to map to the log level of its own severity number
+	for name, level := range severityLevels {
+		assert.Equal(t, level.logLevel, logLevelOf(level.number), name)
+	}
+	assert.Equal(t, "NONE", logLevelOf(plog.SeverityNumberUnspecified))
+	assert.Equal(t, "NOTICE", logLevelOf(plog.SeverityNumberInfo4))
+}
+
+func TestNewSeverityInferenceInvalidPattern(t *testing.T) {
+	_, err := newSeverityInference(SeverityConfig{BodyPatterns: []string{`level=\w+`}})
+	assert.Error(t, err)
+	_, err = newSeverityInference(SeverityConfig{BodyPatterns: []string{`(`}})
+	assert.Error(t, err)
+}
+
+func TestInferSeverity(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetaDataFiles = []string{}
+	cfg.RuxitHostIDFiles = []string{}
+	cfg.Severity.Enabled = true
+	set, _ := newTestTelemetry()
+	proc, err := newDynatraceProcessor(context.Background(), set, cfg, nil)
+	require.NoError(t, err)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Body().SetStr("WARN retrying request")
+	_, err = proc.processLogs(context.Background(), ld)
+	require.NoError(t, err)
+	assert.Equal(t, plog.SeverityNumberWarn, lr.SeverityNumber())
+}
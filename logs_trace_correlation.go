@@ -0,0 +1,108 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// Attributes Dynatrace links log records to traces with
+const (
+	KeyTraceID      = "dt.trace_id"
+	KeySpanID       = "dt.span_id"
+	KeyTraceSampled = "dt.trace_sampled"
+)
+
+// correlateTraces aligns the trace context of all log records of the given
+// logs with the attributes Dynatrace links log records to traces with
+func correlateTraces(ld plog.Logs) {
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		sls := rls.At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			lrs := sls.At(j).LogRecords()
+			for k := 0; k < lrs.Len(); k++ {
+				correlateTrace(lrs.At(k))
+			}
+		}
+	}
+}
+
+// correlateTrace copies the trace ID, the span ID and the sampled flag of
+// the given log record into the attributes `dt.trace_id`, `dt.span_id` and
+// `dt.trace_sampled`. If the log record has no trace ID, but these attributes,
+// the trace context of the log record is getting populated from them instead.
+func correlateTrace(lr plog.LogRecord) {
+	attrs := lr.Attributes()
+	if !lr.TraceID().IsEmpty() {
+		attrs.PutStr(KeyTraceID, lr.TraceID().String())
+		if !lr.SpanID().IsEmpty() {
+			attrs.PutStr(KeySpanID, lr.SpanID().String())
+		}
+		attrs.PutBool(KeyTraceSampled, lr.Flags().IsSampled())
+		return
+	}
+
+	var traceID pcommon.TraceID
+	if !parseHexID(attrs, KeyTraceID, traceID[:]) {
+		return
+	}
+	lr.SetTraceID(traceID)
+	var spanID pcommon.SpanID
+	if lr.SpanID().IsEmpty() && parseHexID(attrs, KeySpanID, spanID[:]) {
+		lr.SetSpanID(spanID)
+	}
+	if sampled, found := attrs.Get(KeyTraceSampled); found {
+		switch sampled.Type() {
+		case pcommon.ValueTypeBool:
+			lr.SetFlags(lr.Flags().WithIsSampled(sampled.Bool()))
+		case pcommon.ValueTypeStr:
+			if value, err := strconv.ParseBool(sampled.Str()); err == nil {
+				lr.SetFlags(lr.Flags().WithIsSampled(value))
+			}
+		}
+	}
+}
+
+// parseHexID decodes the hex encoded ID contained in the attribute with the
+// given key into `id`. Returns false if the attribute doesn't exist, isn't
+// a hex string of the expected length or contains an all-zero ID.
+func parseHexID(attrs pcommon.Map, key string, id []byte) bool {
+	value, found := attrs.Get(key)
+	if !found || value.Type() != pcommon.ValueTypeStr {
+		return false
+	}
+	s := strings.TrimSpace(value.Str())
+	if hex.DecodedLen(len(s)) != len(id) {
+		return false
+	}
+	if _, err := hex.Decode(id, []byte(s)); err != nil {
+		return false
+	}
+	for _, b := range id {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
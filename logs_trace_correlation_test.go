@@ -0,0 +1,145 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+var (
+	testTraceID = pcommon.TraceID([16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36})
+	testSpanID  = pcommon.SpanID([8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7})
+)
+
+func TestCorrelateTraceToAttributes(t *testing.T) {
+	lr := plog.NewLogRecord()
+	lr.SetTraceID(testTraceID)
+	lr.SetSpanID(testSpanID)
+	lr.SetFlags(plog.DefaultLogRecordFlags.WithIsSampled(true))
+	lr.Attributes().PutStr(KeyTraceID, "outdated")
+
+	correlateTrace(lr)
+
+	assert.Equal(t, map[string]any{
+		KeyTraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+		KeySpanID:       "00f067aa0ba902b7",
+		KeyTraceSampled: true,
+	}, lr.Attributes().AsRaw())
+}
+
+func TestCorrelateTraceWithoutSpanID(t *testing.T) {
+	lr := plog.NewLogRecord()
+	lr.SetTraceID(testTraceID)
+
+	correlateTrace(lr)
+
+	assert.Equal(t, map[string]any{
+		KeyTraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+		KeyTraceSampled: false,
+	}, lr.Attributes().AsRaw())
+}
+
+func TestCorrelateTraceFromAttributes(t *testing.T) {
+	tests := []struct {
+		name            string
+		attributes      map[string]any
+		expectedTraceID pcommon.TraceID
+		expectedSpanID  pcommon.SpanID
+		expectedSampled bool
+	}{
+		{
+			name: "all_attributes",
+			attributes: map[string]any{
+				KeyTraceID:      "4BF92F3577B34DA6A3CE929D0E0E4736",
+				KeySpanID:       "00f067aa0ba902b7",
+				KeyTraceSampled: true,
+			},
+			expectedTraceID: testTraceID,
+			expectedSpanID:  testSpanID,
+			expectedSampled: true,
+		},
+		{
+			name: "sampled_as_string",
+			attributes: map[string]any{
+				KeyTraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+				KeyTraceSampled: "true",
+			},
+			expectedTraceID: testTraceID,
+			expectedSampled: true,
+		},
+		{
+			name: "invalid_span_id",
+			attributes: map[string]any{
+				KeyTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				KeySpanID:  "00f067aa",
+			},
+			expectedTraceID: testTraceID,
+		},
+		{
+			name:       "all_zero_trace_id",
+			attributes: map[string]any{KeyTraceID: "00000000000000000000000000000000", KeySpanID: "00f067aa0ba902b7"},
+		},
+		{
+			name:       "invalid_hex",
+			attributes: map[string]any{KeyTraceID: "4bf92f3577b34da6a3ce929d0e0e473x"},
+		},
+		{
+			name:       "no_attributes",
+			attributes: map[string]any{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lr := plog.NewLogRecord()
+			require.NoError(t, lr.Attributes().FromRaw(tt.attributes))
+
+			correlateTrace(lr)
+
+			assert.Equal(t, tt.expectedTraceID, lr.TraceID())
+			assert.Equal(t, tt.expectedSpanID, lr.SpanID())
+			assert.Equal(t, tt.expectedSampled, lr.Flags().IsSampled())
+			assert.Equal(t, tt.attributes, lr.Attributes().AsRaw(), "attributes are expected to remain untouched")
+		})
+	}
+}
+
+func TestCorrelateTraces(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetaDataFiles = []string{}
+	cfg.RuxitHostIDFiles = []string{}
+	cfg.TraceCorrelation.Enabled = true
+	set, _ := newTestTelemetry()
+	proc, err := newDynatraceProcessor(context.Background(), set, cfg, nil)
+	require.NoError(t, err)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.SetTraceID(testTraceID)
+	_, err = proc.processLogs(context.Background(), ld)
+	require.NoError(t, err)
+
+	traceID, found := lr.Attributes().Get(KeyTraceID)
+	require.True(t, found)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID.Str())
+}
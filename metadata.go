@@ -0,0 +1,63 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+)
+
+// Metadata holds the key/value pairs found in a OneAgent
+// enrichment file, e.g. `dt.entity.host`, `dt.host_group.id`
+// or `dt.entity.process_group_instance`
+type Metadata map[string]string
+
+// EvalMetadata attempts to evaluate all key/value pairs contained
+// in the first `dt_metadata.properties` or `dt_metadata.json` file
+// on the current host which exists and isn't empty.
+// The `.properties` files are getting looked at first.
+// If none of these files contains valid content or none of these
+// files exists an empty Metadata is getting returned
+func EvalMetadata(ctx context.Context) Metadata {
+	return enrichmentFilesFromContext(ctx).evalMetadata()
+}
+
+// filter returns the subset of the metadata permitted by the given
+// allow-list and deny-list.
+// An empty allow-list permits every key. The deny-list always wins.
+func (md Metadata) filter(include []string, exclude []string) Metadata {
+	allowed := make(map[string]bool, len(include))
+	for _, key := range include {
+		allowed[key] = true
+	}
+	denied := make(map[string]bool, len(exclude))
+	for _, key := range exclude {
+		denied[key] = true
+	}
+
+	result := Metadata{}
+	for key, value := range md {
+		if len(allowed) > 0 && !allowed[key] {
+			continue
+		}
+		if denied[key] {
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
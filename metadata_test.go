@@ -0,0 +1,142 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataEvaluation(t *testing.T) {
+	tests := []struct {
+		name                    string
+		metaDataPropertiesFiles []string
+		metaDataJSONFiles       []string
+		expectedMetadata        dynatraceprocessor.Metadata
+	}{
+		{
+			name:                    "no_files_configured",
+			metaDataPropertiesFiles: []string{},
+			expectedMetadata:        dynatraceprocessor.Metadata{},
+		},
+		{
+			name: "all_keys",
+			metaDataPropertiesFiles: []string{
+				`# comment
+				dt.entity.host=HOST-AAF98EFF909EE3F6
+				dt.host_group.id = production
+
+				dt.entity.process_group_instance=PROCESS_GROUP_INSTANCE-1A2B3C4D5E6F7A8B
+				asdfsdf`,
+			},
+			expectedMetadata: dynatraceprocessor.Metadata{
+				"dt.entity.host":                   "HOST-AAF98EFF909EE3F6",
+				"dt.host_group.id":                 "production",
+				"dt.entity.process_group_instance": "PROCESS_GROUP_INSTANCE-1A2B3C4D5E6F7A8B",
+			},
+		},
+		{
+			name: "first_occurrence_wins",
+			metaDataPropertiesFiles: []string{
+				`dt.host_group.id=production
+				dt.host_group.id=staging`,
+			},
+			expectedMetadata: dynatraceprocessor.Metadata{
+				"dt.host_group.id": "production",
+			},
+		},
+		{
+			name: "first_non_empty_file_wins",
+			metaDataPropertiesFiles: []string{
+				"nil",
+				"",
+				`dt.host_group.id=production`,
+				`dt.host_group.id=staging`,
+			},
+			expectedMetadata: dynatraceprocessor.Metadata{
+				"dt.host_group.id": "production",
+			},
+		},
+		{
+			name: "json",
+			metaDataJSONFiles: []string{
+				`{
+					"dt.entity.host": "HOST-AAF98EFF909EE3F6",
+					"dt.host_group.id": "production",
+					"dt.entity.process_group_instance.pid": 4711,
+					"dt.kubernetes.enabled": true,
+					"dt.nested": {"key": "value"},
+					"dt.list": ["value"],
+					"dt.null": null
+				}`,
+			},
+			expectedMetadata: dynatraceprocessor.Metadata{
+				"dt.entity.host":                       "HOST-AAF98EFF909EE3F6",
+				"dt.host_group.id":                     "production",
+				"dt.entity.process_group_instance.pid": "4711",
+				"dt.kubernetes.enabled":                "true",
+			},
+		},
+		{
+			name:                    "properties_before_json",
+			metaDataPropertiesFiles: []string{`dt.host_group.id=production`},
+			metaDataJSONFiles:       []string{`{"dt.host_group.id": "staging"}`},
+			expectedMetadata: dynatraceprocessor.Metadata{
+				"dt.host_group.id": "production",
+			},
+		},
+		{
+			name:              "invalid_json_is_skipped",
+			metaDataJSONFiles: []string{`{"dt.host_group.id": `, `{"dt.host_group.id": "staging"}`},
+			expectedMetadata: dynatraceprocessor.Metadata{
+				"dt.host_group.id": "staging",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			metaDataPropertiesFilePaths := []string{}
+			for i, content := range tt.metaDataPropertiesFiles {
+				filePath := filepath.Join(dir, fmt.Sprintf("dt_metadata_%d.properties", i))
+				if content != "nil" {
+					require.NoError(t, os.WriteFile(filePath, []byte(content), 0o600))
+				}
+				metaDataPropertiesFilePaths = append(metaDataPropertiesFilePaths, filePath)
+			}
+			metaDataJSONFilePaths := []string{}
+			for i, content := range tt.metaDataJSONFiles {
+				filePath := filepath.Join(dir, fmt.Sprintf("dt_metadata_%d.json", i))
+				require.NoError(t, os.WriteFile(filePath, []byte(content), 0o600))
+				metaDataJSONFilePaths = append(metaDataJSONFilePaths, filePath)
+			}
+			ctx := context.WithValue(context.Background(), dynatraceprocessor.CtxKeyMetaDataPropertiesFilePaths, metaDataPropertiesFilePaths)
+			ctx = context.WithValue(ctx, dynatraceprocessor.CtxKeyMetaDataJSONFilePaths, metaDataJSONFilePaths)
+
+			assert.Equal(t, tt.expectedMetadata, dynatraceprocessor.EvalMetadata(ctx))
+		})
+	}
+}
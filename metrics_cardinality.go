@@ -0,0 +1,399 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/pdatautil"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// CardinalityAction defines what happens to data points of new
+// series once a metric exceeds its cardinality limit
+type CardinalityAction string
+
+const (
+	// CardinalityActionDrop drops data points of new series
+	CardinalityActionDrop CardinalityAction = "drop"
+	// CardinalityActionOverflow replaces all attribute values of
+	// data points of new series with OverflowValue and merges
+	// the resulting data points of a metric into one
+	CardinalityActionOverflow CardinalityAction = "overflow"
+)
+
+// OverflowValue replaces the attribute values of data points
+// exceeding the cardinality limit of their metric
+const OverflowValue = "dt.overflow"
+
+// maxCardinalitySweepInterval limits how long expired series are
+// getting retained at most, regardless of the window
+const maxCardinalitySweepInterval = time.Minute
+
+// Validate checks if the cardinality action is known
+func (a CardinalityAction) Validate() error {
+	switch a {
+	case CardinalityActionDrop, CardinalityActionOverflow:
+		return nil
+	}
+	return fmt.Errorf("unknown action %q, expected one of %q or %q", a, CardinalityActionDrop, CardinalityActionOverflow)
+}
+
+// metricSeries holds the series seen for a metric within the window
+type metricSeries struct {
+	lastSeen map[[16]byte]time.Time
+	// limited is true once the limit has been exceeded and got logged
+	limited bool
+}
+
+// cardinalityLimiter limits the number of distinct attribute sets per
+// metric name. Series without data points for longer than the window
+// are getting forgotten and no longer count against the limit.
+type cardinalityLimiter struct {
+	logger    *zap.Logger
+	telemetry *processorTelemetry
+	limit     int
+	window    time.Duration
+	action    CardinalityAction
+	now       func() time.Time
+
+	mu        sync.Mutex
+	metrics   map[string]*metricSeries
+	lastSweep time.Time
+}
+
+func newCardinalityLimiter(logger *zap.Logger, telemetry *processorTelemetry, cfg CardinalityLimitConfig) *cardinalityLimiter {
+	return &cardinalityLimiter{
+		logger:    logger,
+		telemetry: telemetry,
+		limit:     cfg.MaxSeriesPerMetric,
+		window:    cfg.Window,
+		action:    cfg.Action,
+		now:       time.Now,
+		metrics:   map[string]*metricSeries{},
+	}
+}
+
+// limitCardinality drops data points of new series of metrics which
+// exceeded their limit, or collapses them into a single data point per
+// metric and attribute keys, depending on the configured action.
+// Metrics left without data points are getting removed.
+func (c *cardinalityLimiter) limitCardinality(ctx context.Context, md pmetric.Metrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.expire(now)
+
+	var limited int64
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			sms.At(j).Metrics().RemoveIf(func(m pmetric.Metric) bool {
+				series := c.series(m.Name())
+				var overflow []int
+				kept := 0
+				empty := removeDataPointsIf(m, func(attrs pcommon.Map) bool {
+					if c.admit(m.Name(), series, attrs, now) {
+						kept++
+						return false
+					}
+					limited++
+					if c.action == CardinalityActionDrop {
+						return true
+					}
+					attrs.Range(func(_ string, v pcommon.Value) bool {
+						v.SetStr(OverflowValue)
+						return true
+					})
+					overflow = append(overflow, kept)
+					kept++
+					return false
+				})
+				mergeOverflowDataPoints(m, overflow)
+				return empty
+			})
+		}
+	}
+	if limited > 0 {
+		c.telemetry.cardinalityLimited.Add(ctx, limited, metric.WithAttributes(attribute.String("action", string(c.action))))
+	}
+}
+
+// series returns the series seen for the metric with the given name
+func (c *cardinalityLimiter) series(name string) *metricSeries {
+	series, found := c.metrics[name]
+	if !found {
+		series = &metricSeries{lastSeen: map[[16]byte]time.Time{}}
+		c.metrics[name] = series
+	}
+	return series
+}
+
+// admit returns true if the series identified by the given attributes
+// is known already or the limit of the metric allows to add it
+func (c *cardinalityLimiter) admit(name string, series *metricSeries, attrs pcommon.Map, now time.Time) bool {
+	key := pdatautil.MapHash(attrs)
+	if _, found := series.lastSeen[key]; found || len(series.lastSeen) < c.limit {
+		series.lastSeen[key] = now
+		return true
+	}
+	if !series.limited {
+		series.limited = true
+		c.logger.Warn("Cardinality limit of metric exceeded",
+			zap.String("metric", name),
+			zap.Int("max_series_per_metric", c.limit),
+			zap.String("action", string(c.action)))
+	}
+	return false
+}
+
+// expire forgets about series without data points within the window
+func (c *cardinalityLimiter) expire(now time.Time) {
+	if now.Sub(c.lastSweep) < min(c.window, maxCardinalitySweepInterval) {
+		return
+	}
+	c.lastSweep = now
+	for name, series := range c.metrics {
+		for key, lastSeen := range series.lastSeen {
+			if now.Sub(lastSeen) > c.window {
+				delete(series.lastSeen, key)
+			}
+		}
+		if len(series.lastSeen) == 0 {
+			delete(c.metrics, name)
+		}
+	}
+}
+
+// removeDataPointsIf removes the data points of the given metric
+// whose attributes match the given function.
+// Returns true if the metric is left without data points.
+func removeDataPointsIf(m pmetric.Metric, fn func(attrs pcommon.Map) bool) bool {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		dps := m.Gauge().DataPoints()
+		dps.RemoveIf(func(dp pmetric.NumberDataPoint) bool { return fn(dp.Attributes()) })
+		return dps.Len() == 0
+	case pmetric.MetricTypeSum:
+		dps := m.Sum().DataPoints()
+		dps.RemoveIf(func(dp pmetric.NumberDataPoint) bool { return fn(dp.Attributes()) })
+		return dps.Len() == 0
+	case pmetric.MetricTypeHistogram:
+		dps := m.Histogram().DataPoints()
+		dps.RemoveIf(func(dp pmetric.HistogramDataPoint) bool { return fn(dp.Attributes()) })
+		return dps.Len() == 0
+	case pmetric.MetricTypeExponentialHistogram:
+		dps := m.ExponentialHistogram().DataPoints()
+		dps.RemoveIf(func(dp pmetric.ExponentialHistogramDataPoint) bool { return fn(dp.Attributes()) })
+		return dps.Len() == 0
+	case pmetric.MetricTypeSummary:
+		dps := m.Summary().DataPoints()
+		dps.RemoveIf(func(dp pmetric.SummaryDataPoint) bool { return fn(dp.Attributes()) })
+		return dps.Len() == 0
+	}
+	return false
+}
+
+// mergeOverflowDataPoints merges the data points of the given metric at
+// the given indices which share the same attributes into the first one
+// of them: values of sums and histograms are getting added up, while
+// gauges and summaries keep the last data point. Histograms whose buckets
+// don't match keep the last data point as well.
+func mergeOverflowDataPoints(m pmetric.Metric, indices []int) {
+	if len(indices) < 2 {
+		return
+	}
+	var merged map[int]bool
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		dps := m.Gauge().DataPoints()
+		merged = groupDataPoints(indices, func(i int) pcommon.Map { return dps.At(i).Attributes() }, func(dst, src int) {
+			dps.At(src).CopyTo(dps.At(dst))
+		})
+	case pmetric.MetricTypeSum:
+		dps := m.Sum().DataPoints()
+		merged = groupDataPoints(indices, func(i int) pcommon.Map { return dps.At(i).Attributes() }, func(dst, src int) {
+			mergeNumberDataPoints(dps.At(dst), dps.At(src))
+		})
+	case pmetric.MetricTypeHistogram:
+		dps := m.Histogram().DataPoints()
+		merged = groupDataPoints(indices, func(i int) pcommon.Map { return dps.At(i).Attributes() }, func(dst, src int) {
+			mergeHistogramDataPoints(dps.At(dst), dps.At(src))
+		})
+	case pmetric.MetricTypeExponentialHistogram:
+		dps := m.ExponentialHistogram().DataPoints()
+		merged = groupDataPoints(indices, func(i int) pcommon.Map { return dps.At(i).Attributes() }, func(dst, src int) {
+			mergeExponentialHistogramDataPoints(dps.At(dst), dps.At(src))
+		})
+	case pmetric.MetricTypeSummary:
+		dps := m.Summary().DataPoints()
+		merged = groupDataPoints(indices, func(i int) pcommon.Map { return dps.At(i).Attributes() }, func(dst, src int) {
+			dps.At(src).CopyTo(dps.At(dst))
+		})
+	}
+	i := -1
+	removeDataPointsIf(m, func(pcommon.Map) bool {
+		i++
+		return merged[i]
+	})
+}
+
+// groupDataPoints merges each of the data points at the given indices
+// into the first one with the same attributes, using the given function.
+// Returns the indices of the data points which got merged into another one.
+func groupDataPoints(indices []int, attrs func(i int) pcommon.Map, merge func(dst, src int)) map[int]bool {
+	merged := map[int]bool{}
+	targets := map[[16]byte]int{}
+	for _, i := range indices {
+		key := pdatautil.MapHash(attrs(i))
+		if target, found := targets[key]; found {
+			merge(target, i)
+			merged[i] = true
+			continue
+		}
+		targets[key] = i
+	}
+	return merged
+}
+
+// timestampedDataPoint is implemented by the data points of all metric types
+type timestampedDataPoint interface {
+	StartTimestamp() pcommon.Timestamp
+	SetStartTimestamp(pcommon.Timestamp)
+	Timestamp() pcommon.Timestamp
+	SetTimestamp(pcommon.Timestamp)
+}
+
+// mergeTimestamps widens the time range of `dst` to cover the given one
+func mergeTimestamps(dst timestampedDataPoint, start pcommon.Timestamp, timestamp pcommon.Timestamp) {
+	if start != 0 && (dst.StartTimestamp() == 0 || start < dst.StartTimestamp()) {
+		dst.SetStartTimestamp(start)
+	}
+	if timestamp > dst.Timestamp() {
+		dst.SetTimestamp(timestamp)
+	}
+}
+
+// mergeNumberDataPoints adds the value of `src` to `dst`
+func mergeNumberDataPoints(dst pmetric.NumberDataPoint, src pmetric.NumberDataPoint) {
+	mergeTimestamps(dst, src.StartTimestamp(), src.Timestamp())
+	if dst.ValueType() == pmetric.NumberDataPointValueTypeInt && src.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		dst.SetIntValue(dst.IntValue() + src.IntValue())
+		return
+	}
+	dst.SetDoubleValue(numberValue(dst) + numberValue(src))
+}
+
+func numberValue(dp pmetric.NumberDataPoint) float64 {
+	if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		return float64(dp.IntValue())
+	}
+	return dp.DoubleValue()
+}
+
+// mergeHistogramDataPoints adds the counts of `src` to `dst`.
+// If their bucket boundaries differ, `src` replaces `dst`.
+func mergeHistogramDataPoints(dst pmetric.HistogramDataPoint, src pmetric.HistogramDataPoint) {
+	if !slices.Equal(dst.ExplicitBounds().AsRaw(), src.ExplicitBounds().AsRaw()) ||
+		dst.BucketCounts().Len() != src.BucketCounts().Len() {
+		src.CopyTo(dst)
+		return
+	}
+	mergeTimestamps(dst, src.StartTimestamp(), src.Timestamp())
+	dst.SetCount(dst.Count() + src.Count())
+	if dst.HasSum() && src.HasSum() {
+		dst.SetSum(dst.Sum() + src.Sum())
+	} else {
+		dst.RemoveSum()
+	}
+	if dst.HasMin() && src.HasMin() {
+		dst.SetMin(min(dst.Min(), src.Min()))
+	} else {
+		dst.RemoveMin()
+	}
+	if dst.HasMax() && src.HasMax() {
+		dst.SetMax(max(dst.Max(), src.Max()))
+	} else {
+		dst.RemoveMax()
+	}
+	for i := 0; i < dst.BucketCounts().Len(); i++ {
+		dst.BucketCounts().SetAt(i, dst.BucketCounts().At(i)+src.BucketCounts().At(i))
+	}
+}
+
+// mergeExponentialHistogramDataPoints adds the counts of `src` to `dst`.
+// If their scale or zero threshold differ, `src` replaces `dst`.
+func mergeExponentialHistogramDataPoints(dst pmetric.ExponentialHistogramDataPoint, src pmetric.ExponentialHistogramDataPoint) {
+	if dst.Scale() != src.Scale() || dst.ZeroThreshold() != src.ZeroThreshold() {
+		src.CopyTo(dst)
+		return
+	}
+	mergeTimestamps(dst, src.StartTimestamp(), src.Timestamp())
+	dst.SetCount(dst.Count() + src.Count())
+	dst.SetZeroCount(dst.ZeroCount() + src.ZeroCount())
+	if dst.HasSum() && src.HasSum() {
+		dst.SetSum(dst.Sum() + src.Sum())
+	} else {
+		dst.RemoveSum()
+	}
+	if dst.HasMin() && src.HasMin() {
+		dst.SetMin(min(dst.Min(), src.Min()))
+	} else {
+		dst.RemoveMin()
+	}
+	if dst.HasMax() && src.HasMax() {
+		dst.SetMax(max(dst.Max(), src.Max()))
+	} else {
+		dst.RemoveMax()
+	}
+	mergeExponentialBuckets(dst.Positive(), src.Positive())
+	mergeExponentialBuckets(dst.Negative(), src.Negative())
+}
+
+// mergeExponentialBuckets adds the bucket counts of `src` to `dst`,
+// extending `dst` to cover the buckets of both
+func mergeExponentialBuckets(dst pmetric.ExponentialHistogramDataPointBuckets, src pmetric.ExponentialHistogramDataPointBuckets) {
+	if src.BucketCounts().Len() == 0 {
+		return
+	}
+	if dst.BucketCounts().Len() == 0 {
+		src.CopyTo(dst)
+		return
+	}
+	offset := min(dst.Offset(), src.Offset())
+	end := max(dst.Offset()+int32(dst.BucketCounts().Len()), src.Offset()+int32(src.BucketCounts().Len()))
+	counts := make([]uint64, end-offset)
+	for i, count := range dst.BucketCounts().AsRaw() {
+		counts[dst.Offset()-offset+int32(i)] += count
+	}
+	for i, count := range src.BucketCounts().AsRaw() {
+		counts[src.Offset()-offset+int32(i)] += count
+	}
+	dst.SetOffset(offset)
+	dst.BucketCounts().FromRaw(counts)
+}
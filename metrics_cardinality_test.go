@@ -0,0 +1,224 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestGauge(name string, users ...string) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName(name)
+	dps := m.SetEmptyGauge().DataPoints()
+	for _, user := range users {
+		dp := dps.AppendEmpty()
+		dp.Attributes().PutStr("user", user)
+		dp.Attributes().PutStr("region", "eu")
+		dp.SetIntValue(1)
+	}
+	return md
+}
+
+func gaugeAttributes(md pmetric.Metrics) []map[string]any {
+	var attrs []map[string]any
+	ms := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < ms.Len(); i++ {
+		dps := ms.At(i).Gauge().DataPoints()
+		for j := 0; j < dps.Len(); j++ {
+			attrs = append(attrs, dps.At(j).Attributes().AsRaw())
+		}
+	}
+	return attrs
+}
+
+func newTestCardinalityLimiter(t *testing.T, action CardinalityAction) (*cardinalityLimiter, *testTelemetry, *observer.ObservedLogs) {
+	set, tt := newTestTelemetry()
+	telemetry, err := newProcessorTelemetry(set.TelemetrySettings)
+	require.NoError(t, err)
+	core, logs := observer.New(zap.WarnLevel)
+	c := newCardinalityLimiter(zap.New(core), telemetry, CardinalityLimitConfig{
+		MaxSeriesPerMetric: 2,
+		Window:             time.Hour,
+		Action:             action,
+	})
+	return c, tt, logs
+}
+
+func TestCardinalityLimiterOverflow(t *testing.T) {
+	c, telemetry, logs := newTestCardinalityLimiter(t, CardinalityActionOverflow)
+
+	md := newTestGauge("requests", "a", "b", "c", "a")
+	c.limitCardinality(context.Background(), md)
+	assert.Equal(t, []map[string]any{
+		{"user": "a", "region": "eu"},
+		{"user": "b", "region": "eu"},
+		{"user": OverflowValue, "region": OverflowValue},
+		{"user": "a", "region": "eu"},
+	}, gaugeAttributes(md))
+	assert.Equal(t, int64(1), telemetry.sum(t, "processor_dynatrace_cardinality_limited_data_points"))
+
+	// the limit applies per metric
+	md = newTestGauge("logins", "c")
+	c.limitCardinality(context.Background(), md)
+	assert.Equal(t, []map[string]any{{"user": "c", "region": "eu"}}, gaugeAttributes(md))
+
+	// the limit is expected to be logged once per metric
+	c.limitCardinality(context.Background(), newTestGauge("requests", "d"))
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "requests", logs.All()[0].ContextMap()["metric"])
+}
+
+func TestCardinalityLimiterOverflowMerges(t *testing.T) {
+	c, telemetry, _ := newTestCardinalityLimiter(t, CardinalityActionOverflow)
+
+	md := newTestGauge("requests", "a", "b", "c", "d", "e")
+	dps := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints()
+	dps.At(4).SetIntValue(5)
+	c.limitCardinality(context.Background(), md)
+	// gauges keep the last data point
+	assert.Equal(t, []map[string]any{
+		{"user": "a", "region": "eu"},
+		{"user": "b", "region": "eu"},
+		{"user": OverflowValue, "region": OverflowValue},
+	}, gaugeAttributes(md))
+	assert.Equal(t, int64(5), dps.At(2).IntValue())
+	assert.Equal(t, int64(3), telemetry.sum(t, "processor_dynatrace_cardinality_limited_data_points"))
+
+	md = pmetric.NewMetrics()
+	m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("bytes")
+	sum := m.SetEmptySum()
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	for i, user := range []string{"a", "b", "c", "d", "e"} {
+		dp := sum.DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("user", user)
+		dp.SetStartTimestamp(pcommon.Timestamp(10))
+		dp.SetTimestamp(pcommon.Timestamp(20 + i))
+		dp.SetIntValue(int64(i + 1))
+	}
+	c.limitCardinality(context.Background(), md)
+	// sums get added up
+	require.Equal(t, 3, sum.DataPoints().Len())
+	overflow := sum.DataPoints().At(2)
+	assert.Equal(t, map[string]any{"user": OverflowValue}, overflow.Attributes().AsRaw())
+	assert.Equal(t, int64(3+4+5), overflow.IntValue())
+	assert.Equal(t, pcommon.Timestamp(10), overflow.StartTimestamp())
+	assert.Equal(t, pcommon.Timestamp(24), overflow.Timestamp())
+}
+
+func TestMergeHistogramDataPoints(t *testing.T) {
+	dst := pmetric.NewHistogramDataPoint()
+	dst.SetCount(3)
+	dst.SetSum(6)
+	dst.SetMin(1)
+	dst.ExplicitBounds().FromRaw([]float64{5})
+	dst.BucketCounts().FromRaw([]uint64{3, 0})
+	src := pmetric.NewHistogramDataPoint()
+	src.SetCount(2)
+	src.SetSum(20)
+	src.ExplicitBounds().FromRaw([]float64{5})
+	src.BucketCounts().FromRaw([]uint64{0, 2})
+
+	mergeHistogramDataPoints(dst, src)
+	assert.Equal(t, uint64(5), dst.Count())
+	assert.Equal(t, 26.0, dst.Sum())
+	assert.False(t, dst.HasMin(), "min is expected to be removed if missing in either data point")
+	assert.Equal(t, []uint64{3, 2}, dst.BucketCounts().AsRaw())
+
+	// differing bounds can't be merged, the last data point wins
+	src.ExplicitBounds().FromRaw([]float64{10})
+	mergeHistogramDataPoints(dst, src)
+	assert.Equal(t, uint64(2), dst.Count())
+	assert.Equal(t, []float64{10}, dst.ExplicitBounds().AsRaw())
+}
+
+func TestMergeExponentialBuckets(t *testing.T) {
+	dst := pmetric.NewExponentialHistogramDataPointBuckets()
+	dst.SetOffset(2)
+	dst.BucketCounts().FromRaw([]uint64{1, 1})
+	src := pmetric.NewExponentialHistogramDataPointBuckets()
+	src.SetOffset(0)
+	src.BucketCounts().FromRaw([]uint64{1, 0, 1})
+
+	mergeExponentialBuckets(dst, src)
+	assert.Equal(t, int32(0), dst.Offset())
+	assert.Equal(t, []uint64{1, 0, 2, 1}, dst.BucketCounts().AsRaw())
+}
+
+func TestCardinalityLimiterDrop(t *testing.T) {
+	c, telemetry, _ := newTestCardinalityLimiter(t, CardinalityActionDrop)
+
+	md := newTestGauge("requests", "a", "b", "c")
+	c.limitCardinality(context.Background(), md)
+	assert.Equal(t, []map[string]any{
+		{"user": "a", "region": "eu"},
+		{"user": "b", "region": "eu"},
+	}, gaugeAttributes(md))
+
+	md = newTestGauge("requests", "d", "e")
+	c.limitCardinality(context.Background(), md)
+	assert.Equal(t, 0, md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().Len(),
+		"metrics without data points are expected to be removed")
+	assert.Equal(t, int64(3), telemetry.sum(t, "processor_dynatrace_cardinality_limited_data_points"))
+}
+
+func TestCardinalityLimiterWindow(t *testing.T) {
+	c, _, _ := newTestCardinalityLimiter(t, CardinalityActionDrop)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.limitCardinality(context.Background(), newTestGauge("requests", "a", "b"))
+	now = now.Add(30 * time.Minute)
+	c.limitCardinality(context.Background(), newTestGauge("requests", "a"))
+	now = now.Add(45 * time.Minute)
+
+	// `b` is expected to have expired, `a` not
+	md := newTestGauge("requests", "c", "d")
+	c.limitCardinality(context.Background(), md)
+	assert.Equal(t, []map[string]any{{"user": "c", "region": "eu"}}, gaugeAttributes(md))
+}
+
+func TestCardinalityLimiterProcessor(t *testing.T) {
+	set, telemetry := newTestTelemetry()
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetaDataFiles = []string{}
+	cfg.RuxitHostIDFiles = []string{}
+	cfg.CardinalityLimit.Enabled = true
+	cfg.CardinalityLimit.MaxSeriesPerMetric = 10
+	proc, err := newDynatraceProcessor(context.Background(), set, cfg, nil)
+	require.NoError(t, err)
+
+	var users []string
+	for i := 0; i < 15; i++ {
+		users = append(users, fmt.Sprintf("user-%d", i))
+	}
+	_, err = proc.processMetrics(context.Background(), newTestGauge("requests", users...))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), telemetry.sum(t, "processor_dynatrace_cardinality_limited_data_points"))
+}
@@ -0,0 +1,180 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// SummaryMode defines how summaries are getting treated,
+// since Dynatrace doesn't accept them
+type SummaryMode string
+
+const (
+	// SummaryModeConvert replaces summaries with a gauge for their quantiles
+	// and sums for their count and their sum
+	SummaryModeConvert SummaryMode = "convert"
+	// SummaryModeDrop removes summaries
+	SummaryModeDrop SummaryMode = "drop"
+)
+
+// Suffixes and attributes of the metrics a summary is getting converted into
+const (
+	SummaryCountSuffix       = ".count"
+	SummarySumSuffix         = ".sum"
+	SummaryQuantileAttribute = "quantile"
+)
+
+// Validate checks if the summary mode is known
+func (m SummaryMode) Validate() error {
+	switch m {
+	case SummaryModeConvert, SummaryModeDrop:
+		return nil
+	}
+	return fmt.Errorf("unknown summary mode %q, expected one of %q or %q", m, SummaryModeConvert, SummaryModeDrop)
+}
+
+// values of the `action` attribute of the incompatible metrics counter
+const (
+	compatActionDropped   = "dropped"
+	compatActionConverted = "converted"
+)
+
+// makeMetricsCompatible removes or converts metrics Dynatrace can't ingest:
+// summaries, metrics without a type and sums or histograms without
+// an aggregation temporality.
+func (rp *dynatraceProcessor) makeMetricsCompatible(ctx context.Context, md pmetric.Metrics) {
+	counts := map[[2]string]int64{}
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			ms := sms.At(j).Metrics()
+			var converted []pmetric.Metric
+			ms.RemoveIf(func(m pmetric.Metric) bool {
+				if m.Type() == pmetric.MetricTypeSummary {
+					if rp.metrics.summaryMode == SummaryModeConvert {
+						converted = append(converted, convertSummary(m)...)
+						counts[[2]string{compatActionConverted, m.Type().String()}]++
+					} else {
+						counts[[2]string{compatActionDropped, m.Type().String()}]++
+					}
+					return true
+				}
+				if !isCompatibleMetric(m) {
+					counts[[2]string{compatActionDropped, m.Type().String()}]++
+					return true
+				}
+				return false
+			})
+			for _, m := range converted {
+				m.MoveTo(ms.AppendEmpty())
+			}
+		}
+	}
+	for key, count := range counts {
+		rp.telemetry.incompatibleMetrics.Add(ctx, count, metric.WithAttributes(
+			attribute.String("action", key[0]),
+			attribute.String("type", key[1])))
+	}
+}
+
+// isCompatibleMetric checks whether the type and the
+// aggregation temporality of the given metric are known
+func isCompatibleMetric(m pmetric.Metric) bool {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		return true
+	case pmetric.MetricTypeSum:
+		return m.Sum().AggregationTemporality() != pmetric.AggregationTemporalityUnspecified
+	case pmetric.MetricTypeHistogram:
+		return m.Histogram().AggregationTemporality() != pmetric.AggregationTemporalityUnspecified
+	case pmetric.MetricTypeExponentialHistogram:
+		return m.ExponentialHistogram().AggregationTemporality() != pmetric.AggregationTemporalityUnspecified
+	}
+	return false
+}
+
+// convertSummary converts the given summary into a gauge named like the
+// summary, containing a data point per quantile, and into cumulative sums
+// for the count and the sum of the summary.
+// Metrics which would end up without data points are getting omitted.
+func convertSummary(m pmetric.Metric) []pmetric.Metric {
+	quantiles := pmetric.NewMetric()
+	quantiles.SetName(m.Name())
+	quantiles.SetDescription(m.Description())
+	quantiles.SetUnit(m.Unit())
+	gauge := quantiles.SetEmptyGauge()
+
+	count := pmetric.NewMetric()
+	count.SetName(m.Name() + SummaryCountSuffix)
+	count.SetDescription(m.Description())
+	countSum := count.SetEmptySum()
+	countSum.SetIsMonotonic(true)
+	countSum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+
+	sum := pmetric.NewMetric()
+	sum.SetName(m.Name() + SummarySumSuffix)
+	sum.SetDescription(m.Description())
+	sum.SetUnit(m.Unit())
+	sumSum := sum.SetEmptySum()
+	sumSum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+
+	dps := m.Summary().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		for k := 0; k < dp.QuantileValues().Len(); k++ {
+			qv := dp.QuantileValues().At(k)
+			qdp := gauge.DataPoints().AppendEmpty()
+			dp.Attributes().CopyTo(qdp.Attributes())
+			qdp.Attributes().PutDouble(SummaryQuantileAttribute, qv.Quantile())
+			qdp.SetStartTimestamp(dp.StartTimestamp())
+			qdp.SetTimestamp(dp.Timestamp())
+			qdp.SetDoubleValue(qv.Value())
+		}
+
+		cdp := countSum.DataPoints().AppendEmpty()
+		dp.Attributes().CopyTo(cdp.Attributes())
+		cdp.SetStartTimestamp(dp.StartTimestamp())
+		cdp.SetTimestamp(dp.Timestamp())
+		cdp.SetIntValue(int64(dp.Count()))
+
+		sdp := sumSum.DataPoints().AppendEmpty()
+		dp.Attributes().CopyTo(sdp.Attributes())
+		sdp.SetStartTimestamp(dp.StartTimestamp())
+		sdp.SetTimestamp(dp.Timestamp())
+		sdp.SetDoubleValue(dp.Sum())
+	}
+
+	var metrics []pmetric.Metric
+	for _, converted := range []pmetric.Metric{quantiles, count, sum} {
+		if converted.Type() == pmetric.MetricTypeGauge && converted.Gauge().DataPoints().Len() == 0 {
+			continue
+		}
+		if converted.Type() == pmetric.MetricTypeSum && converted.Sum().DataPoints().Len() == 0 {
+			continue
+		}
+		metrics = append(metrics, converted)
+	}
+	return metrics
+}
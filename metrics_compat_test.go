@@ -0,0 +1,131 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor/testdata"
+)
+
+func newCompatProcessor(t *testing.T, summaryMode SummaryMode) (*dynatraceProcessor, *testTelemetry) {
+	set, telemetry := newTestTelemetry()
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetaDataFiles = []string{}
+	cfg.RuxitHostIDFiles = []string{}
+	cfg.MetricCompat = MetricCompatConfig{Enabled: true, Summary: summaryMode}
+	proc, err := newDynatraceProcessor(context.Background(), set, cfg, nil)
+	require.NoError(t, err)
+	return proc, telemetry
+}
+
+func metricsByName(md pmetric.Metrics) map[string]pmetric.Metric {
+	metrics := map[string]pmetric.Metric{}
+	ms := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < ms.Len(); i++ {
+		metrics[ms.At(i).Name()] = ms.At(i)
+	}
+	return metrics
+}
+
+func TestMetricCompatConvertSummary(t *testing.T) {
+	proc, telemetry := newCompatProcessor(t, SummaryModeConvert)
+
+	md := testdata.GenerateMetricsOneCounterOneSummaryMetrics()
+	_, err := proc.processMetrics(context.Background(), md)
+	require.NoError(t, err)
+
+	metrics := metricsByName(md)
+	require.Len(t, metrics, 4)
+	assert.Contains(t, metrics, testdata.TestSumIntMetricName)
+
+	quantiles := metrics[testdata.TestDoubleSummaryMetricName]
+	require.Equal(t, pmetric.MetricTypeGauge, quantiles.Type())
+	require.Equal(t, 1, quantiles.Gauge().DataPoints().Len())
+	qdp := quantiles.Gauge().DataPoints().At(0)
+	assert.Equal(t, 15.0, qdp.DoubleValue())
+	quantile, found := qdp.Attributes().Get(SummaryQuantileAttribute)
+	require.True(t, found)
+	assert.Equal(t, 0.01, quantile.Double())
+
+	count := metrics[testdata.TestDoubleSummaryMetricName+SummaryCountSuffix]
+	require.Equal(t, pmetric.MetricTypeSum, count.Type())
+	assert.True(t, count.Sum().IsMonotonic())
+	assert.Equal(t, pmetric.AggregationTemporalityCumulative, count.Sum().AggregationTemporality())
+	require.Equal(t, 2, count.Sum().DataPoints().Len())
+	assert.Equal(t, int64(1), count.Sum().DataPoints().At(0).IntValue())
+
+	sum := metrics[testdata.TestDoubleSummaryMetricName+SummarySumSuffix]
+	require.Equal(t, pmetric.MetricTypeSum, sum.Type())
+	require.Equal(t, 2, sum.Sum().DataPoints().Len())
+	assert.Equal(t, 15.0, sum.Sum().DataPoints().At(1).DoubleValue())
+	assert.Equal(t, count.Sum().DataPoints().At(1).Attributes().AsRaw(), sum.Sum().DataPoints().At(1).Attributes().AsRaw())
+
+	assert.Equal(t, int64(1), telemetry.sum(t, "processor_dynatrace_incompatible_metrics"))
+}
+
+func TestMetricCompatDropSummary(t *testing.T) {
+	proc, telemetry := newCompatProcessor(t, SummaryModeDrop)
+
+	md := testdata.GenerateMetricsOneCounterOneSummaryMetrics()
+	_, err := proc.processMetrics(context.Background(), md)
+	require.NoError(t, err)
+
+	metrics := metricsByName(md)
+	assert.Len(t, metrics, 1)
+	assert.Contains(t, metrics, testdata.TestSumIntMetricName)
+	assert.Equal(t, int64(1), telemetry.sum(t, "processor_dynatrace_incompatible_metrics"))
+}
+
+func TestMetricCompatDropUnsupported(t *testing.T) {
+	proc, telemetry := newCompatProcessor(t, SummaryModeDrop)
+
+	md := testdata.GenerateMetricsAllTypesEmptyDataPoint()
+	md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().AppendEmpty().SetName("empty")
+	_, err := proc.processMetrics(context.Background(), md)
+	require.NoError(t, err)
+
+	metrics := metricsByName(md)
+	assert.Contains(t, metrics, testdata.TestGaugeDoubleMetricName)
+	assert.Contains(t, metrics, testdata.TestSumIntMetricName)
+	assert.Contains(t, metrics, testdata.TestDoubleHistogramMetricName)
+	// no aggregation temporality
+	assert.NotContains(t, metrics, testdata.TestExponentialHistogramMetricName)
+	assert.NotContains(t, metrics, testdata.TestDoubleSummaryMetricName)
+	assert.NotContains(t, metrics, "empty")
+	assert.Equal(t, int64(3), telemetry.sum(t, "processor_dynatrace_incompatible_metrics"))
+}
+
+func TestMetricCompatDisabled(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetaDataFiles = []string{}
+	cfg.RuxitHostIDFiles = []string{}
+	set, _ := newTestTelemetry()
+	proc, err := newDynatraceProcessor(context.Background(), set, cfg, nil)
+	require.NoError(t, err)
+
+	md := testdata.GenerateMetricsOneCounterOneSummaryMetrics()
+	_, err = proc.processMetrics(context.Background(), md)
+	require.NoError(t, err)
+	assert.Equal(t, pmetric.MetricTypeSummary, metricsByName(md)[testdata.TestDoubleSummaryMetricName].Type())
+}
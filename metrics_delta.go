@@ -0,0 +1,366 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/pdatautil"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// streamKey identifies a stream of data points by
+// resource, scope, metric and data point attributes
+type streamKey [16]byte
+
+// streamState holds the most recent cumulative data point of a stream
+type streamState struct {
+	lastSeen  time.Time
+	start     pcommon.Timestamp
+	timestamp pcommon.Timestamp
+
+	// Sum
+	intValue    int64
+	doubleValue float64
+
+	// Histogram and ExponentialHistogram
+	count        uint64
+	sum          float64
+	bucketCounts []uint64
+	bounds       []float64
+
+	// ExponentialHistogram
+	scale           int32
+	zeroCount       uint64
+	positiveOffset  int32
+	negativeOffset  int32
+	negativeBuckets []uint64
+	zeroThreshold   float64
+}
+
+// deltaConverter converts cumulative sums and histograms into deltas.
+// The most recent data point of every stream is kept in memory,
+// bounded by the maximum number of streams and evicted once stale.
+type deltaConverter struct {
+	logger       *zap.Logger
+	maxStreams   int
+	maxStaleness time.Duration
+	now          func() time.Time
+
+	mu        sync.Mutex
+	streams   map[streamKey]*streamState
+	lastSweep time.Time
+	full      bool
+}
+
+func newDeltaConverter(logger *zap.Logger, maxStreams int, maxStaleness time.Duration) *deltaConverter {
+	return &deltaConverter{
+		logger:       logger,
+		maxStreams:   maxStreams,
+		maxStaleness: maxStaleness,
+		now:          time.Now,
+		streams:      map[streamKey]*streamState{},
+	}
+}
+
+// convert replaces the cumulative data points of sums, histograms and
+// exponential histograms with deltas to the previous data point of their
+// stream. The first data point of a stream is getting dropped, since there
+// is nothing to compute a delta against. Metrics left without data points
+// are getting removed.
+func (c *deltaConverter) convert(md pmetric.Metrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.evictStale(now)
+
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		sms := rm.ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			sm := sms.At(j)
+			sm.Metrics().RemoveIf(func(m pmetric.Metric) bool {
+				keyOf := func(attrs pcommon.Map) streamKey {
+					return streamKey(pdatautil.Hash(
+						pdatautil.WithMap(rm.Resource().Attributes()),
+						pdatautil.WithString(sm.Scope().Name()),
+						pdatautil.WithString(sm.Scope().Version()),
+						pdatautil.WithString(m.Name()),
+						pdatautil.WithString(m.Type().String()),
+						pdatautil.WithMap(attrs)))
+				}
+				switch m.Type() {
+				case pmetric.MetricTypeSum:
+					sum := m.Sum()
+					if sum.AggregationTemporality() != pmetric.AggregationTemporalityCumulative {
+						return false
+					}
+					sum.DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+						return !c.convertNumber(keyOf(dp.Attributes()), dp, sum.IsMonotonic(), now)
+					})
+					sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+					return sum.DataPoints().Len() == 0
+				case pmetric.MetricTypeHistogram:
+					histogram := m.Histogram()
+					if histogram.AggregationTemporality() != pmetric.AggregationTemporalityCumulative {
+						return false
+					}
+					histogram.DataPoints().RemoveIf(func(dp pmetric.HistogramDataPoint) bool {
+						return !c.convertHistogram(keyOf(dp.Attributes()), dp, now)
+					})
+					histogram.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+					return histogram.DataPoints().Len() == 0
+				case pmetric.MetricTypeExponentialHistogram:
+					histogram := m.ExponentialHistogram()
+					if histogram.AggregationTemporality() != pmetric.AggregationTemporalityCumulative {
+						return false
+					}
+					histogram.DataPoints().RemoveIf(func(dp pmetric.ExponentialHistogramDataPoint) bool {
+						return !c.convertExponentialHistogram(keyOf(dp.Attributes()), dp, now)
+					})
+					histogram.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+					return histogram.DataPoints().Len() == 0
+				}
+				return false
+			})
+		}
+	}
+}
+
+// evictStale forgets about streams which haven't received
+// any data points for longer than the maximum staleness
+func (c *deltaConverter) evictStale(now time.Time) {
+	if c.maxStaleness <= 0 || now.Sub(c.lastSweep) < c.maxStaleness {
+		return
+	}
+	c.lastSweep = now
+	for key, state := range c.streams {
+		if now.Sub(state.lastSeen) > c.maxStaleness {
+			delete(c.streams, key)
+		}
+	}
+	if len(c.streams) < c.maxStreams {
+		c.full = false
+	}
+}
+
+// track returns the state of the stream with the given key.
+// If the stream isn't known yet, a new state is getting stored and
+// `found` is false. If the maximum number of streams has been reached,
+// the returned state is nil.
+func (c *deltaConverter) track(key streamKey, now time.Time) (state *streamState, found bool) {
+	if state, found := c.streams[key]; found {
+		state.lastSeen = now
+		return state, true
+	}
+	if c.maxStreams > 0 && len(c.streams) >= c.maxStreams {
+		if !c.full {
+			c.full = true
+			c.logger.Warn("Maximum number of streams for cumulative to delta conversion reached, dropping data points of new streams",
+				zap.Int("max_streams", c.maxStreams))
+		}
+		return nil, false
+	}
+	state = &streamState{lastSeen: now}
+	c.streams[key] = state
+	return state, false
+}
+
+// convertNumber converts the given cumulative sum data point into a delta.
+// Returns false if the data point needs to be dropped.
+func (c *deltaConverter) convertNumber(key streamKey, dp pmetric.NumberDataPoint, monotonic bool, now time.Time) bool {
+	state, found := c.track(key, now)
+	if state == nil {
+		return false
+	}
+	if found && dp.Timestamp() <= state.timestamp {
+		// out of order or duplicate
+		return false
+	}
+
+	previous := *state
+	state.start, state.timestamp = dp.StartTimestamp(), dp.Timestamp()
+	state.intValue, state.doubleValue = dp.IntValue(), dp.DoubleValue()
+	if !found {
+		return false
+	}
+
+	if dp.StartTimestamp() != previous.start {
+		// the cumulative value already is the delta since the reset
+		return true
+	}
+	if monotonic {
+		reset := false
+		switch dp.ValueType() {
+		case pmetric.NumberDataPointValueTypeInt:
+			reset = dp.IntValue() < previous.intValue
+		case pmetric.NumberDataPointValueTypeDouble:
+			reset = dp.DoubleValue() < previous.doubleValue
+		}
+		if reset {
+			// the start timestamp is unknown, the cumulative value is
+			// the delta since the previous data point at the earliest
+			dp.SetStartTimestamp(previous.timestamp)
+			return true
+		}
+	}
+
+	dp.SetStartTimestamp(previous.timestamp)
+	switch dp.ValueType() {
+	case pmetric.NumberDataPointValueTypeInt:
+		dp.SetIntValue(dp.IntValue() - previous.intValue)
+	case pmetric.NumberDataPointValueTypeDouble:
+		dp.SetDoubleValue(dp.DoubleValue() - previous.doubleValue)
+	}
+	return true
+}
+
+// convertHistogram converts the given cumulative histogram data point
+// into a delta. Returns false if the data point needs to be dropped.
+func (c *deltaConverter) convertHistogram(key streamKey, dp pmetric.HistogramDataPoint, now time.Time) bool {
+	state, found := c.track(key, now)
+	if state == nil {
+		return false
+	}
+	if found && dp.Timestamp() <= state.timestamp {
+		return false
+	}
+
+	previous := *state
+	state.start, state.timestamp = dp.StartTimestamp(), dp.Timestamp()
+	state.count, state.sum = dp.Count(), dp.Sum()
+	state.bucketCounts = dp.BucketCounts().AsRaw()
+	state.bounds = dp.ExplicitBounds().AsRaw()
+	if !found {
+		return false
+	}
+
+	// min and max of the cumulative data point don't apply to the delta
+	dp.RemoveMin()
+	dp.RemoveMax()
+
+	if dp.StartTimestamp() != previous.start {
+		return true
+	}
+	if dp.Count() < previous.count ||
+		!slices.Equal(state.bounds, previous.bounds) ||
+		!subtractBuckets(state.bucketCounts, previous.bucketCounts, nil) {
+		// a reset without a new start timestamp, see convertNumber
+		dp.SetStartTimestamp(previous.timestamp)
+		return true
+	}
+
+	deltas := make([]uint64, len(state.bucketCounts))
+	subtractBuckets(state.bucketCounts, previous.bucketCounts, deltas)
+	dp.SetStartTimestamp(previous.timestamp)
+	dp.SetCount(dp.Count() - previous.count)
+	if dp.HasSum() {
+		dp.SetSum(dp.Sum() - previous.sum)
+	}
+	dp.BucketCounts().FromRaw(deltas)
+	return true
+}
+
+// convertExponentialHistogram converts the given cumulative exponential
+// histogram data point into a delta.
+// Returns false if the data point needs to be dropped.
+func (c *deltaConverter) convertExponentialHistogram(key streamKey, dp pmetric.ExponentialHistogramDataPoint, now time.Time) bool {
+	state, found := c.track(key, now)
+	if state == nil {
+		return false
+	}
+	if found && dp.Timestamp() <= state.timestamp {
+		return false
+	}
+
+	previous := *state
+	state.start, state.timestamp = dp.StartTimestamp(), dp.Timestamp()
+	state.count, state.sum = dp.Count(), dp.Sum()
+	state.scale, state.zeroCount = dp.Scale(), dp.ZeroCount()
+	state.zeroThreshold = dp.ZeroThreshold()
+	state.positiveOffset, state.bucketCounts = dp.Positive().Offset(), dp.Positive().BucketCounts().AsRaw()
+	state.negativeOffset, state.negativeBuckets = dp.Negative().Offset(), dp.Negative().BucketCounts().AsRaw()
+	if !found {
+		return false
+	}
+
+	dp.RemoveMin()
+	dp.RemoveMax()
+
+	if dp.StartTimestamp() != previous.start {
+		return true
+	}
+	// buckets can only be subtracted if their layout didn't change
+	if dp.Count() < previous.count ||
+		dp.ZeroCount() < previous.zeroCount ||
+		state.scale != previous.scale ||
+		state.zeroThreshold != previous.zeroThreshold ||
+		state.positiveOffset != previous.positiveOffset ||
+		state.negativeOffset != previous.negativeOffset ||
+		!subtractBuckets(state.bucketCounts, previous.bucketCounts, nil) ||
+		!subtractBuckets(state.negativeBuckets, previous.negativeBuckets, nil) {
+		// a reset without a new start timestamp, see convertNumber
+		dp.SetStartTimestamp(previous.timestamp)
+		return true
+	}
+
+	positive := make([]uint64, len(state.bucketCounts))
+	subtractBuckets(state.bucketCounts, previous.bucketCounts, positive)
+	negative := make([]uint64, len(state.negativeBuckets))
+	subtractBuckets(state.negativeBuckets, previous.negativeBuckets, negative)
+
+	dp.SetStartTimestamp(previous.timestamp)
+	dp.SetCount(dp.Count() - previous.count)
+	if dp.HasSum() {
+		dp.SetSum(dp.Sum() - previous.sum)
+	}
+	dp.SetZeroCount(dp.ZeroCount() - previous.zeroCount)
+	dp.Positive().BucketCounts().FromRaw(positive)
+	dp.Negative().BucketCounts().FromRaw(negative)
+	return true
+}
+
+// subtractBuckets computes the delta between the current and the previous
+// bucket counts into `deltas`, if not nil.
+// Buckets missing in `previous` are treated as empty.
+// Returns false if any bucket count decreased or buckets disappeared,
+// i.e. if the counts have been reset.
+func subtractBuckets(current []uint64, previous []uint64, deltas []uint64) bool {
+	if len(previous) > len(current) {
+		return false
+	}
+	for i, count := range current {
+		var previousCount uint64
+		if i < len(previous) {
+			previousCount = previous[i]
+		}
+		if count < previousCount {
+			return false
+		}
+		if deltas != nil {
+			deltas[i] = count - previousCount
+		}
+	}
+	return true
+}
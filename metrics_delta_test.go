@@ -0,0 +1,274 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+func newTestSum(start, timestamp pcommon.Timestamp, value int64, attrs map[string]any) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "test")
+	m := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("requests")
+	sum := m.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	dp := sum.DataPoints().AppendEmpty()
+	dp.SetStartTimestamp(start)
+	dp.SetTimestamp(timestamp)
+	dp.SetIntValue(value)
+	_ = dp.Attributes().FromRaw(attrs)
+	return md
+}
+
+func newTestHistogram(start, timestamp pcommon.Timestamp, count uint64, sum float64, buckets []uint64, bounds []float64) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("duration")
+	histogram := m.SetEmptyHistogram()
+	histogram.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	dp := histogram.DataPoints().AppendEmpty()
+	dp.SetStartTimestamp(start)
+	dp.SetTimestamp(timestamp)
+	dp.SetCount(count)
+	dp.SetSum(sum)
+	dp.SetMin(1)
+	dp.SetMax(10)
+	dp.BucketCounts().FromRaw(buckets)
+	dp.ExplicitBounds().FromRaw(bounds)
+	return md
+}
+
+func firstMetric(md pmetric.Metrics) (pmetric.Metric, bool) {
+	sms := md.ResourceMetrics().At(0).ScopeMetrics().At(0)
+	if sms.Metrics().Len() == 0 {
+		return pmetric.Metric{}, false
+	}
+	return sms.Metrics().At(0), true
+}
+
+func TestDeltaConverterSum(t *testing.T) {
+	c := newDeltaConverter(zap.NewNop(), 0, 0)
+
+	md := newTestSum(1, 10, 5, nil)
+	c.convert(md)
+	_, found := firstMetric(md)
+	assert.False(t, found, "first data point of a stream is expected to be dropped")
+
+	md = newTestSum(1, 20, 8, nil)
+	c.convert(md)
+	m, found := firstMetric(md)
+	require.True(t, found)
+	assert.Equal(t, pmetric.AggregationTemporalityDelta, m.Sum().AggregationTemporality())
+	dp := m.Sum().DataPoints().At(0)
+	assert.Equal(t, int64(3), dp.IntValue())
+	assert.Equal(t, pcommon.Timestamp(10), dp.StartTimestamp())
+	assert.Equal(t, pcommon.Timestamp(20), dp.Timestamp())
+}
+
+func TestDeltaConverterStreams(t *testing.T) {
+	c := newDeltaConverter(zap.NewNop(), 0, 0)
+
+	c.convert(newTestSum(1, 10, 5, map[string]any{"path": "/a"}))
+	md := newTestSum(1, 20, 7, map[string]any{"path": "/b"})
+	c.convert(md)
+	_, found := firstMetric(md)
+	assert.False(t, found, "different attributes are expected to be a separate stream")
+
+	md = newTestSum(1, 20, 7, map[string]any{"path": "/a"})
+	c.convert(md)
+	m, found := firstMetric(md)
+	require.True(t, found)
+	assert.Equal(t, int64(2), m.Sum().DataPoints().At(0).IntValue())
+}
+
+func TestDeltaConverterReset(t *testing.T) {
+	tests := []struct {
+		name          string
+		start         pcommon.Timestamp
+		value         int64
+		expectedStart pcommon.Timestamp
+	}{
+		// the start is unchanged, hence the delta can't start before the previous data point
+		{name: "value_decreased", start: 1, value: 2, expectedStart: 10},
+		{name: "start_time_changed", start: 15, value: 9, expectedStart: 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newDeltaConverter(zap.NewNop(), 0, 0)
+			c.convert(newTestSum(1, 10, 5, nil))
+
+			md := newTestSum(tt.start, 20, tt.value, nil)
+			c.convert(md)
+			m, found := firstMetric(md)
+			require.True(t, found)
+			dp := m.Sum().DataPoints().At(0)
+			assert.Equal(t, tt.value, dp.IntValue())
+			assert.Equal(t, tt.expectedStart, dp.StartTimestamp())
+
+			// the reset data point is expected to be the new reference
+			md = newTestSum(tt.start, 30, tt.value+1, nil)
+			c.convert(md)
+			m, found = firstMetric(md)
+			require.True(t, found)
+			assert.Equal(t, int64(1), m.Sum().DataPoints().At(0).IntValue())
+		})
+	}
+}
+
+func TestDeltaConverterOutOfOrder(t *testing.T) {
+	c := newDeltaConverter(zap.NewNop(), 0, 0)
+	c.convert(newTestSum(1, 20, 5, nil))
+
+	md := newTestSum(1, 10, 3, nil)
+	c.convert(md)
+	_, found := firstMetric(md)
+	assert.False(t, found)
+}
+
+func TestDeltaConverterKeepsDelta(t *testing.T) {
+	c := newDeltaConverter(zap.NewNop(), 0, 0)
+	md := newTestSum(1, 10, 5, nil)
+	md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	c.convert(md)
+	m, found := firstMetric(md)
+	require.True(t, found)
+	assert.Equal(t, int64(5), m.Sum().DataPoints().At(0).IntValue())
+	assert.Empty(t, c.streams)
+}
+
+func TestDeltaConverterHistogram(t *testing.T) {
+	c := newDeltaConverter(zap.NewNop(), 0, 0)
+	c.convert(newTestHistogram(1, 10, 3, 12, []uint64{1, 2, 0}, []float64{5, 10}))
+
+	md := newTestHistogram(1, 20, 6, 30, []uint64{2, 3, 1}, []float64{5, 10})
+	c.convert(md)
+	m, found := firstMetric(md)
+	require.True(t, found)
+	assert.Equal(t, pmetric.AggregationTemporalityDelta, m.Histogram().AggregationTemporality())
+	dp := m.Histogram().DataPoints().At(0)
+	assert.Equal(t, uint64(3), dp.Count())
+	assert.Equal(t, 18.0, dp.Sum())
+	assert.Equal(t, []uint64{1, 1, 1}, dp.BucketCounts().AsRaw())
+	assert.Equal(t, pcommon.Timestamp(10), dp.StartTimestamp())
+	assert.False(t, dp.HasMin())
+	assert.False(t, dp.HasMax())
+
+	// changed bounds are expected to be treated as a reset
+	md = newTestHistogram(1, 30, 7, 31, []uint64{2, 5}, []float64{8})
+	c.convert(md)
+	m, found = firstMetric(md)
+	require.True(t, found)
+	dp = m.Histogram().DataPoints().At(0)
+	assert.Equal(t, uint64(7), dp.Count())
+	assert.Equal(t, []uint64{2, 5}, dp.BucketCounts().AsRaw())
+	assert.Equal(t, pcommon.Timestamp(20), dp.StartTimestamp())
+}
+
+func TestDeltaConverterExponentialHistogram(t *testing.T) {
+	newExponentialHistogram := func(timestamp pcommon.Timestamp, count uint64, scale int32, positive []uint64) pmetric.Metrics {
+		md := pmetric.NewMetrics()
+		m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		m.SetName("latency")
+		histogram := m.SetEmptyExponentialHistogram()
+		histogram.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		dp := histogram.DataPoints().AppendEmpty()
+		dp.SetStartTimestamp(1)
+		dp.SetTimestamp(timestamp)
+		dp.SetCount(count)
+		dp.SetSum(float64(count))
+		dp.SetScale(scale)
+		dp.Positive().BucketCounts().FromRaw(positive)
+		return md
+	}
+
+	c := newDeltaConverter(zap.NewNop(), 0, 0)
+	c.convert(newExponentialHistogram(10, 3, 2, []uint64{1, 2}))
+
+	md := newExponentialHistogram(20, 6, 2, []uint64{2, 3, 1})
+	c.convert(md)
+	m, found := firstMetric(md)
+	require.True(t, found)
+	dp := m.ExponentialHistogram().DataPoints().At(0)
+	assert.Equal(t, pmetric.AggregationTemporalityDelta, m.ExponentialHistogram().AggregationTemporality())
+	assert.Equal(t, uint64(3), dp.Count())
+	assert.Equal(t, []uint64{1, 1, 1}, dp.Positive().BucketCounts().AsRaw())
+
+	// a missing sum is expected to remain missing
+	md = newExponentialHistogram(25, 8, 2, []uint64{2, 4, 2})
+	firstDataPoint := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).ExponentialHistogram().DataPoints().At(0)
+	firstDataPoint.RemoveSum()
+	c.convert(md)
+	m, found = firstMetric(md)
+	require.True(t, found)
+	dp = m.ExponentialHistogram().DataPoints().At(0)
+	assert.Equal(t, uint64(2), dp.Count())
+	assert.False(t, dp.HasSum())
+
+	// a changed scale is expected to be treated as a reset
+	md = newExponentialHistogram(30, 7, 1, []uint64{4, 3})
+	c.convert(md)
+	m, found = firstMetric(md)
+	require.True(t, found)
+	dp = m.ExponentialHistogram().DataPoints().At(0)
+	assert.Equal(t, uint64(7), dp.Count())
+	assert.Equal(t, []uint64{4, 3}, dp.Positive().BucketCounts().AsRaw())
+	assert.Equal(t, pcommon.Timestamp(25), dp.StartTimestamp())
+}
+
+func TestDeltaConverterMaxStreams(t *testing.T) {
+	c := newDeltaConverter(zap.NewNop(), 1, 0)
+	c.convert(newTestSum(1, 10, 5, map[string]any{"path": "/a"}))
+	c.convert(newTestSum(1, 10, 5, map[string]any{"path": "/b"}))
+	assert.Len(t, c.streams, 1)
+
+	md := newTestSum(1, 20, 7, map[string]any{"path": "/b"})
+	c.convert(md)
+	_, found := firstMetric(md)
+	assert.False(t, found, "data points of untracked streams are expected to be dropped")
+}
+
+func TestDeltaConverterStaleness(t *testing.T) {
+	now := time.Now()
+	c := newDeltaConverter(zap.NewNop(), 1, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.convert(newTestSum(1, 10, 5, map[string]any{"path": "/a"}))
+	now = now.Add(2 * time.Minute)
+	c.convert(newTestSum(1, 10, 5, map[string]any{"path": "/b"}))
+
+	md := newTestSum(1, 20, 7, map[string]any{"path": "/b"})
+	c.convert(md)
+	m, found := firstMetric(md)
+	require.True(t, found, "stale streams are expected to be evicted")
+	assert.Equal(t, int64(2), m.Sum().DataPoints().At(0).IntValue())
+
+	md = newTestSum(1, 20, 7, map[string]any{"path": "/a"})
+	c.convert(md)
+	_, found = firstMetric(md)
+	assert.False(t, found, "evicted streams are expected to start over")
+}
@@ -0,0 +1,251 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// Limits of the Dynatrace metric ingestion
+const (
+	maxMetricKeyLength      = 250
+	maxDimensionKeyLength   = 100
+	maxDimensionValueLength = 250
+)
+
+// DefaultOriginalNameAttribute is the data point attribute the original
+// name of a metric is getting recorded in, if the name had to be rewritten
+const DefaultOriginalNameAttribute = "dt.metric.original_name"
+
+// normalizeMetrics rewrites metric names and data point attributes
+// violating the Dynatrace metric ingestion rules.
+// Metrics whose name doesn't contain anything valid, e.g. `123`,
+// are getting dropped.
+func (rp *dynatraceProcessor) normalizeMetrics(ctx context.Context, md pmetric.Metrics) {
+	var names, dimensions, invalid int64
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			ms := sms.At(j).Metrics()
+			ms.RemoveIf(func(m pmetric.Metric) bool {
+				if normalizeMetricKey(m.Name()) == "" {
+					invalid++
+					return true
+				}
+				return false
+			})
+			for k := 0; k < ms.Len(); k++ {
+				m := ms.At(k)
+				original := m.Name()
+				if normalized := normalizeMetricKey(original); normalized != original {
+					m.SetName(normalized)
+					names++
+					if rp.metrics.originalNameAttribute != "" {
+						forEachDataPointAttributes(m, func(attrs pcommon.Map) {
+							attrs.PutStr(rp.metrics.originalNameAttribute, truncate(original, maxDimensionValueLength))
+						})
+					}
+				}
+				forEachDataPointAttributes(m, func(attrs pcommon.Map) {
+					dimensions += normalizeDimensions(attrs)
+				})
+			}
+		}
+	}
+	if names > 0 {
+		rp.telemetry.normalizedMetricNames.Add(ctx, names)
+	}
+	if dimensions > 0 {
+		rp.telemetry.normalizedDimensions.Add(ctx, dimensions)
+	}
+	if invalid > 0 {
+		rp.telemetry.invalidMetricNames.Add(ctx, invalid)
+	}
+}
+
+// forEachDataPointAttributes invokes the given function
+// for the attributes of every data point of the given metric
+func forEachDataPointAttributes(m pmetric.Metric, fn func(attrs pcommon.Map)) {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		dps := m.Gauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeSum:
+		dps := m.Sum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeHistogram:
+		dps := m.Histogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		dps := m.ExponentialHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeSummary:
+		dps := m.Summary().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).Attributes())
+		}
+	}
+}
+
+// normalizeDimensions rewrites the keys and the string values of the
+// given data point attributes and returns the number of rewritten attributes.
+// If two keys end up being identical, only the first one is getting kept.
+func normalizeDimensions(attrs pcommon.Map) int64 {
+	var rewritten int64
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		if normalizeDimensionKey(k) != k {
+			rewritten++
+		} else if v.Type() == pcommon.ValueTypeStr && normalizeDimensionValue(v.Str()) != v.Str() {
+			rewritten++
+		}
+		return true
+	})
+	if rewritten == 0 {
+		return 0
+	}
+
+	normalized := pcommon.NewMap()
+	normalized.EnsureCapacity(attrs.Len())
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		key := normalizeDimensionKey(k)
+		if key == "" {
+			return true
+		}
+		if _, found := normalized.Get(key); found {
+			return true
+		}
+		if v.Type() == pcommon.ValueTypeStr {
+			normalized.PutStr(key, normalizeDimensionValue(v.Str()))
+		} else {
+			v.CopyTo(normalized.PutEmpty(key))
+		}
+		return true
+	})
+	normalized.CopyTo(attrs)
+	return rewritten
+}
+
+// normalizeMetricKey rewrites the given metric name to comply with the
+// Dynatrace rules for metric keys: sections separated by dots, consisting
+// of letters, digits, hyphens and underscores, starting with a letter
+// (the first section) or a letter, digit or underscore (any other section)
+// with a total length of at most 250 characters.
+// Invalid characters are getting replaced with underscores, empty sections
+// are getting removed. Returns "" if nothing valid remains.
+func normalizeMetricKey(name string) string {
+	sections := strings.Split(name, ".")
+	normalized := make([]string, 0, len(sections))
+	for _, section := range sections {
+		var sb strings.Builder
+		for _, r := range section {
+			switch {
+			case isASCIILetter(r), isASCIIDigit(r), r == '-', r == '_':
+				sb.WriteRune(r)
+			default:
+				sb.WriteRune('_')
+			}
+		}
+		s := sb.String()
+		if len(normalized) == 0 {
+			s = strings.TrimLeftFunc(s, func(r rune) bool { return !isASCIILetter(r) })
+		} else {
+			s = strings.TrimLeft(s, "-")
+		}
+		if s == "" {
+			continue
+		}
+		normalized = append(normalized, s)
+	}
+	return strings.TrimRight(truncate(strings.Join(normalized, "."), maxMetricKeyLength), ".")
+}
+
+// normalizeDimensionKey rewrites the given attribute key to comply with
+// the Dynatrace rules for dimension keys: lower case letters, digits,
+// hyphens, dots, colons and underscores, starting with a letter with
+// a total length of at most 100 characters.
+// Invalid characters are getting replaced with underscores.
+// Returns "" if nothing valid remains.
+func normalizeDimensionKey(key string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(key) {
+		switch {
+		case isASCIILetter(r), isASCIIDigit(r), r == '-', r == '.', r == ':', r == '_':
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	s := strings.TrimLeftFunc(sb.String(), func(r rune) bool { return !isASCIILetter(r) })
+	return truncate(s, maxDimensionKeyLength)
+}
+
+// normalizeDimensionValue removes control characters from the given
+// attribute value and truncates it to at most 250 characters
+func normalizeDimensionValue(value string) string {
+	if !strings.ContainsFunc(value, isControl) && utf8.RuneCountInString(value) <= maxDimensionValueLength {
+		return value
+	}
+	return truncate(strings.Map(func(r rune) rune {
+		if isControl(r) {
+			return -1
+		}
+		return r
+	}, value), maxDimensionValueLength)
+}
+
+// truncate shortens the given string to at most `limit` characters
+// without splitting multi-byte characters
+func truncate(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	count := 0
+	for i := range s {
+		if count == limit {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isASCIIDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
@@ -0,0 +1,125 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestNormalizeMetricKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "http.server.duration", expected: "http.server.duration"},
+		{name: "http_requests-total", expected: "http_requests-total"},
+		{name: "1st.metric", expected: "st.metric"},
+		{name: "_metric", expected: "metric"},
+		{name: "metric.1st", expected: "metric.1st"},
+		{name: "metric..name", expected: "metric.name"},
+		{name: "metric.-name", expected: "metric.name"},
+		{name: "metric name/with:chars", expected: "metric_name_with_chars"},
+		{name: "métric", expected: "m_tric"},
+		{name: "...", expected: ""},
+		{name: "123", expected: ""},
+		{name: strings.Repeat("a", 300), expected: strings.Repeat("a", 250)},
+		{name: strings.Repeat("a", 249) + ".b", expected: strings.Repeat("a", 249)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, normalizeMetricKey(tt.name))
+		})
+	}
+}
+
+func TestNormalizeDimensionKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{key: "dt.entity.host", expected: "dt.entity.host"},
+		{key: "http.Method", expected: "http.method"},
+		{key: "k8s:pod-name_1", expected: "k8s:pod-name_1"},
+		{key: "1key", expected: "key"},
+		{key: "key with spaces", expected: "key_with_spaces"},
+		{key: "100", expected: ""},
+		{key: strings.Repeat("k", 120), expected: strings.Repeat("k", 100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.expected, normalizeDimensionKey(tt.key))
+		})
+	}
+}
+
+func TestNormalizeDimensionValue(t *testing.T) {
+	assert.Equal(t, "value", normalizeDimensionValue("value"))
+	assert.Equal(t, "line1line2", normalizeDimensionValue("line1\nline2"))
+	assert.Equal(t, strings.Repeat("ä", 250), normalizeDimensionValue(strings.Repeat("ä", 260)))
+}
+
+func TestNormalizeMetrics(t *testing.T) {
+	set, telemetry := newTestTelemetry()
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetaDataFiles = []string{}
+	cfg.RuxitHostIDFiles = []string{}
+	cfg.NormalizeMetrics.Enabled = true
+	proc, err := newDynatraceProcessor(context.Background(), set, cfg, nil)
+	require.NoError(t, err)
+
+	md := pmetric.NewMetrics()
+	ms := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	valid := ms.AppendEmpty()
+	valid.SetName("http.server.requests")
+	dp := valid.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.Attributes().PutStr("http.method", "GET")
+	invalid := ms.AppendEmpty()
+	invalid.SetName("1 invalid/name")
+	dp = invalid.SetEmptySum().DataPoints().AppendEmpty()
+	dp.Attributes().PutStr("Http.Method", "GET")
+	dp.Attributes().PutStr("http.method", "POST")
+	dp.Attributes().PutStr("message", "multi\nline")
+	dp.Attributes().PutInt("status", 200)
+	unnamed := ms.AppendEmpty()
+	unnamed.SetName("123")
+	unnamed.SetEmptyGauge().DataPoints().AppendEmpty()
+
+	_, err = proc.processMetrics(context.Background(), md)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, ms.Len())
+	assert.Equal(t, "http.server.requests", valid.Name())
+	assert.Equal(t, map[string]any{"http.method": "GET"}, valid.Gauge().DataPoints().At(0).Attributes().AsRaw())
+	assert.Equal(t, "invalid_name", invalid.Name())
+	assert.Equal(t, map[string]any{
+		"http.method":                "GET",
+		"message":                    "multiline",
+		"status":                     int64(200),
+		DefaultOriginalNameAttribute: "1 invalid/name",
+	}, invalid.Sum().DataPoints().At(0).Attributes().AsRaw())
+
+	assert.Equal(t, int64(1), telemetry.sum(t, "processor_dynatrace_normalized_metric_names"))
+	assert.Equal(t, int64(2), telemetry.sum(t, "processor_dynatrace_normalized_dimensions"))
+	assert.Equal(t, int64(1), telemetry.sum(t, "processor_dynatrace_invalid_metric_names"))
+}
@@ -0,0 +1,82 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultWatchFallbackInterval is the polling interval used in case
+// any of the directories containing the enrichment files can't be
+// watched and no refresh interval has been configured
+var defaultWatchFallbackInterval = time.Minute
+
+// startRefreshing launches the background re-evaluation of the host ID,
+// if a refresh interval has been configured or watching is enabled
+func (en *enrichmentEngine) startRefreshing(done <-chan struct{}) {
+	refreshInterval := en.refreshInterval
+	if en.watch.Enabled {
+		unwatched, err := en.startWatching(done)
+		if err != nil {
+			en.logger.Warn("Unable to watch enrichment files, falling back to polling", zap.Error(err))
+		} else if len(unwatched) > 0 {
+			en.logger.Info("Unable to watch some directories containing enrichment files, polling them", zap.Strings("dirs", unwatched))
+		}
+		if (err != nil || len(unwatched) > 0) && refreshInterval <= 0 {
+			refreshInterval = defaultWatchFallbackInterval
+		}
+	}
+	if refreshInterval > 0 {
+		en.wg.Add(1)
+		go en.refreshLoop(refreshInterval, done)
+	}
+}
+
+func (en *enrichmentEngine) refreshLoop(interval time.Duration, done <-chan struct{}) {
+	defer en.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			en.refresh()
+		}
+	}
+}
+
+// refresh re-evaluates the host ID and the enrichment files and
+// atomically replaces the attributes getting added to signals
+func (en *enrichmentEngine) refresh() {
+	next := en.discover()
+	previous := en.current.Swap(next)
+	if previous.equal(next) {
+		return
+	}
+	if previous.hostID != next.hostID {
+		en.reportStatus(next)
+	}
+	en.logger.Info("Dynatrace enrichment changed",
+		zap.String("previous_host_id", previous.hostID),
+		zap.String("host_id", next.hostID),
+		zap.String("host_id_source", next.hostIDSource),
+		zap.Any("attributes", next.attributes))
+}
@@ -0,0 +1,72 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor"
+)
+
+func TestDynatraceProcessorRefresh(t *testing.T) {
+	ruxitHostIDFilePath := filepath.Join(t.TempDir(), "ruxithost.id")
+	ctx := context.WithValue(context.Background(), dynatraceprocessor.CtxKeyMetaDataPropertiesFilePaths, []string{})
+	ctx = context.WithValue(ctx, dynatraceprocessor.CtxKeyRuxitHostIDFilePaths, []string{ruxitHostIDFilePath})
+
+	cfg := &dynatraceprocessor.Config{Metadata: true, RefreshInterval: 10 * time.Millisecond}
+	sink := new(consumertest.TracesSink)
+	tp, err := dynatraceprocessor.NewFactory().CreateTraces(ctx, processortest.NewNopSettings(), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, tp.Start(ctx, componenttest.NewNopHost()))
+	defer func() {
+		assert.NoError(t, tp.Shutdown(ctx))
+	}()
+
+	hostIDOf := func() string {
+		sink.Reset()
+		require.NoError(t, tp.ConsumeTraces(ctx, generateTraceData(nil)))
+		value, found := sink.AllTraces()[0].ResourceSpans().At(0).Resource().Attributes().Get(dynatraceprocessor.KeyEntityHost)
+		if !found {
+			return ""
+		}
+		return value.Str()
+	}
+
+	require.NoError(t, os.WriteFile(ruxitHostIDFilePath, []byte("AAF98EFF909EE3F6"), 0o600))
+	assert.Eventually(t, func() bool { return hostIDOf() == "HOST-AAF98EFF909EE3F6" }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(ruxitHostIDFilePath, []byte("BBF98EFF909EE3F6"), 0o600))
+	assert.Eventually(t, func() bool { return hostIDOf() == "HOST-BBF98EFF909EE3F6" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDynatraceProcessorShutdownWithoutStart(t *testing.T) {
+	cfg := &dynatraceprocessor.Config{Metadata: true, RefreshInterval: time.Second}
+	tp, err := dynatraceprocessor.NewFactory().CreateTraces(context.Background(), processortest.NewNopSettings(), cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	assert.NoError(t, tp.Shutdown(context.Background()))
+}
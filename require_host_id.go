@@ -0,0 +1,87 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/processor/processorhelper"
+)
+
+// MissingHostIDAction defines what happens to batches containing
+// resources without `dt.entity.host` after the enrichment
+type MissingHostIDAction string
+
+const (
+	// MissingHostIDPass passes batches on regardless
+	MissingHostIDPass MissingHostIDAction = "pass"
+	// MissingHostIDReject fails batches containing resources without
+	// `dt.entity.host`, returning an error to the previous component
+	MissingHostIDReject MissingHostIDAction = "reject"
+	// MissingHostIDDrop silently drops batches containing
+	// resources without `dt.entity.host`
+	MissingHostIDDrop MissingHostIDAction = "drop"
+)
+
+// errMissingHostID is getting returned for rejected batches
+var errMissingHostID = errors.New("resources without dt.entity.host")
+
+// Validate checks if the action is known
+func (a MissingHostIDAction) Validate() error {
+	switch a {
+	case MissingHostIDPass, MissingHostIDReject, MissingHostIDDrop:
+		return nil
+	}
+	return fmt.Errorf("unknown action %q, expected one of %q, %q or %q", a, MissingHostIDPass, MissingHostIDReject, MissingHostIDDrop)
+}
+
+// requireHostID returns an error if the given enrichment has no host ID
+func requireHostID(e *enrichment) error {
+	if len(e.hostID) > 0 || len(e.attributes[KeyEntityHost]) > 0 {
+		return nil
+	}
+	decision := "discovery is disabled"
+	if e.report != nil {
+		decision = e.report.decision
+	}
+	return fmt.Errorf("require_host_id: %w: %s", errNoHostID, decision)
+}
+
+// checkHostID determines whether a batch can be passed on, based on the
+// number of its resources lacking `dt.entity.host` after the enrichment.
+// Returns the error to return for the batch, if any.
+func (rp *dynatraceProcessor) checkHostID(missing int, total int) error {
+	if missing == 0 {
+		return nil
+	}
+	switch rp.onMissingHostID {
+	case MissingHostIDReject:
+		return fmt.Errorf("%w: %d of %d resources", errMissingHostID, missing, total)
+	case MissingHostIDDrop:
+		return processorhelper.ErrSkipProcessingData
+	}
+	return nil
+}
+
+// lacksHostID checks whether the given resource attributes lack `dt.entity.host`
+func lacksHostID(attrs pcommon.Map) bool {
+	value, found := attrs.Get(KeyEntityHost)
+	return !found || value.AsString() == ""
+}
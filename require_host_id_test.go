@@ -0,0 +1,90 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor"
+)
+
+func TestRequireHostID(t *testing.T) {
+	ctx := context.Background()
+	cfg := &dynatraceprocessor.Config{Metadata: true, RequireHostID: true}
+	factory := dynatraceprocessor.NewFactory(dynatraceprocessor.WithFileSystem(fstest.MapFS{}))
+
+	_, err := factory.CreateTraces(ctx, processortest.NewNopSettings(), cfg, new(consumertest.TracesSink))
+	assert.ErrorContains(t, err, "require_host_id")
+	_, err = factory.CreateMetrics(ctx, processortest.NewNopSettings(), cfg, new(consumertest.MetricsSink))
+	assert.Error(t, err)
+	_, err = factory.CreateLogs(ctx, processortest.NewNopSettings(), cfg, new(consumertest.LogsSink))
+	assert.Error(t, err)
+
+	ctx = context.WithValue(ctx, dynatraceprocessor.MetaDataKeyDTEntityHost, "HOST-0123456789ABCDEF")
+	tp, err := factory.CreateTraces(ctx, processortest.NewNopSettings(), cfg, new(consumertest.TracesSink))
+	require.NoError(t, err)
+	assert.NoError(t, tp.Shutdown(ctx))
+}
+
+func TestOnMissingHostID(t *testing.T) {
+	tests := []struct {
+		action        dynatraceprocessor.MissingHostIDAction
+		sourceHostID  string
+		expectError   bool
+		expectBatches int
+	}{
+		{action: dynatraceprocessor.MissingHostIDPass, expectBatches: 1},
+		{action: dynatraceprocessor.MissingHostIDReject, expectError: true},
+		{action: dynatraceprocessor.MissingHostIDDrop},
+		{action: dynatraceprocessor.MissingHostIDReject, sourceHostID: "HOST-FEDCBA9876543210", expectBatches: 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.action)+"_"+tt.sourceHostID, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := &dynatraceprocessor.Config{Metadata: true, OnMissingHostID: tt.action}
+			sink := new(consumertest.LogsSink)
+			factory := dynatraceprocessor.NewFactory(dynatraceprocessor.WithFileSystem(fstest.MapFS{}))
+			lp, err := factory.CreateLogs(ctx, processortest.NewNopSettings(), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, lp.Start(ctx, componenttest.NewNopHost()))
+			defer func() {
+				assert.NoError(t, lp.Shutdown(ctx))
+			}()
+
+			var attributes map[string]string
+			if tt.sourceHostID != "" {
+				attributes = map[string]string{dynatraceprocessor.KeyEntityHost: tt.sourceHostID}
+			}
+			err = lp.ConsumeLogs(ctx, generateLogData(attributes))
+			if tt.expectError {
+				assert.ErrorContains(t, err, "dt.entity.host")
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, sink.AllLogs(), tt.expectBatches)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.uber.org/zap"
+)
+
+// errNoHostID is getting reported as recoverable error
+// as long as no host ID could be discovered
+var errNoHostID = errors.New("no Dynatrace host ID discovered")
+
+// reportStatus reports the outcome of the host ID discovery to the hosts
+// of all processors sharing the engine, if discovering the host ID is enabled
+func (en *enrichmentEngine) reportStatus(e *enrichment) {
+	en.mu.Lock()
+	defer en.mu.Unlock()
+	for _, host := range en.hosts {
+		en.reportStatusTo(host, e)
+	}
+}
+
+// reportStatusTo reports the outcome of the host ID discovery via the
+// component status of the collector, if discovering the host ID is enabled.
+// Without a host ID `StatusRecoverableError` is getting reported,
+// `StatusOK` otherwise.
+func (en *enrichmentEngine) reportStatusTo(host component.Host, e *enrichment) {
+	if !en.metadata || host == nil {
+		return
+	}
+	if len(e.hostID) == 0 {
+		decision := "discovery is pending"
+		if e.report != nil {
+			decision = e.report.decision
+		}
+		componentstatus.ReportStatus(host, componentstatus.NewRecoverableErrorEvent(fmt.Errorf("%w: %s", errNoHostID, decision)))
+		return
+	}
+	// status events can't carry details, hence they are getting logged
+	en.logger.Info("Reporting Dynatrace host ID discovery as healthy",
+		zap.String("host_id", e.hostID),
+		zap.String("source", e.hostIDSource))
+	componentstatus.ReportStatus(host, componentstatus.NewEvent(componentstatus.StatusOK))
+}
@@ -0,0 +1,145 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor"
+)
+
+// statusHost records the status events reported by a component
+type statusHost struct {
+	mu     sync.Mutex
+	events []*componentstatus.Event
+}
+
+var _ componentstatus.Reporter = (*statusHost)(nil)
+
+func (h *statusHost) GetExtensions() map[component.ID]component.Component {
+	return nil
+}
+
+func (h *statusHost) Report(event *componentstatus.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+}
+
+func (h *statusHost) statuses() []componentstatus.Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var statuses []componentstatus.Status
+	for _, event := range h.events {
+		statuses = append(statuses, event.Status())
+	}
+	return statuses
+}
+
+func (h *statusHost) last() *componentstatus.Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.events) == 0 {
+		return nil
+	}
+	return h.events[len(h.events)-1]
+}
+
+func TestStatusWithoutHostID(t *testing.T) {
+	ctx := context.Background()
+	cfg := &dynatraceprocessor.Config{Metadata: true}
+	factory := dynatraceprocessor.NewFactory(dynatraceprocessor.WithFileSystem(fstest.MapFS{}))
+	tp, err := factory.CreateTraces(ctx, processortest.NewNopSettings(), cfg, new(consumertest.TracesSink))
+	require.NoError(t, err)
+
+	host := &statusHost{}
+	require.NoError(t, tp.Start(ctx, host))
+	defer func() {
+		assert.NoError(t, tp.Shutdown(ctx))
+	}()
+
+	event := host.last()
+	require.NotNil(t, event)
+	assert.Equal(t, componentstatus.StatusRecoverableError, event.Status())
+	assert.ErrorContains(t, event.Err(), "no Dynatrace host ID discovered")
+}
+
+func TestStatusWithHostID(t *testing.T) {
+	ctx := context.WithValue(context.Background(), dynatraceprocessor.MetaDataKeyDTEntityHost, "HOST-0123456789ABCDEF")
+	cfg := &dynatraceprocessor.Config{Metadata: true}
+	mp, err := dynatraceprocessor.NewFactory().CreateMetrics(ctx, processortest.NewNopSettings(), cfg, new(consumertest.MetricsSink))
+	require.NoError(t, err)
+
+	host := &statusHost{}
+	require.NoError(t, mp.Start(ctx, host))
+	defer func() {
+		assert.NoError(t, mp.Shutdown(ctx))
+	}()
+
+	assert.Equal(t, []componentstatus.Status{componentstatus.StatusOK}, host.statuses())
+}
+
+func TestStatusWithoutMetadata(t *testing.T) {
+	cfg := &dynatraceprocessor.Config{}
+	lp, err := dynatraceprocessor.NewFactory().CreateLogs(context.Background(), processortest.NewNopSettings(), cfg, new(consumertest.LogsSink))
+	require.NoError(t, err)
+
+	host := &statusHost{}
+	require.NoError(t, lp.Start(context.Background(), host))
+	defer func() {
+		assert.NoError(t, lp.Shutdown(context.Background()))
+	}()
+
+	assert.Empty(t, host.statuses())
+}
+
+func TestStatusRecovers(t *testing.T) {
+	root := t.TempDir()
+	ruxitHostIDFilePath := filepath.Join(root, "var", "lib", "dynatrace", "oneagent", "agent", "config", "ruxithost.id")
+	ctx := context.Background()
+	cfg := &dynatraceprocessor.Config{Metadata: true, RefreshInterval: 10 * time.Millisecond}
+	factory := dynatraceprocessor.NewFactory(dynatraceprocessor.WithFileSystem(os.DirFS(root)))
+	tp, err := factory.CreateTraces(ctx, processortest.NewNopSettings(), cfg, new(consumertest.TracesSink))
+	require.NoError(t, err)
+
+	host := &statusHost{}
+	require.NoError(t, tp.Start(ctx, host))
+	defer func() {
+		assert.NoError(t, tp.Shutdown(ctx))
+	}()
+	require.Equal(t, componentstatus.StatusRecoverableError, host.last().Status())
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(ruxitHostIDFilePath), 0o700))
+	require.NoError(t, os.WriteFile(ruxitHostIDFilePath, []byte("0123456789ABCDEF"), 0o600))
+	assert.Eventually(t, func() bool {
+		return host.last().Status() == componentstatus.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+}
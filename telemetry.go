@@ -0,0 +1,185 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const scopeName = "github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor"
+
+// values of the `signal` attribute of the enrichment counters
+const (
+	signalTraces  = "traces"
+	signalMetrics = "metrics"
+	signalLogs    = "logs"
+)
+
+// enrichmentOutcome describes what happened to
+// `dt.entity.host` on a single resource
+type enrichmentOutcome int
+
+const (
+	// outcomeNoHostID means no host ID has been discovered
+	outcomeNoHostID enrichmentOutcome = iota
+	// outcomeEnriched means the host ID has been added
+	outcomeEnriched
+	// outcomeSkipped means the resource contained `dt.entity.host` already
+	outcomeSkipped
+)
+
+// enrichmentCounts counts the outcomes of the resources of a batch
+type enrichmentCounts struct {
+	enriched int64
+	skipped  int64
+	noHostID int64
+}
+
+func (c *enrichmentCounts) add(outcome enrichmentOutcome) {
+	switch outcome {
+	case outcomeEnriched:
+		c.enriched++
+	case outcomeSkipped:
+		c.skipped++
+	default:
+		c.noHostID++
+	}
+}
+
+// processorTelemetry holds the instruments the processor
+// reports its own behavior with
+type processorTelemetry struct {
+	meter metric.Meter
+
+	enrichedResources     metric.Int64Counter
+	skippedResources      metric.Int64Counter
+	noHostIDResources     metric.Int64Counter
+	hostIDDiscovery       metric.Registration
+	normalizedMetricNames metric.Int64Counter
+	normalizedDimensions  metric.Int64Counter
+	invalidMetricNames    metric.Int64Counter
+	incompatibleMetrics   metric.Int64Counter
+	cardinalityLimited    metric.Int64Counter
+	truncatedLogRecords   metric.Int64Counter
+}
+
+func newProcessorTelemetry(set component.TelemetrySettings) (*processorTelemetry, error) {
+	meter := set.LeveledMeterProvider(configtelemetry.LevelBasic).Meter(scopeName)
+
+	var errs, err error
+	telemetry := &processorTelemetry{meter: meter}
+	telemetry.enrichedResources, err = meter.Int64Counter(
+		"processor_dynatrace_enriched_resources",
+		metric.WithDescription("Number of resources dt.entity.host has been added to"),
+		metric.WithUnit("{resources}"))
+	errs = errors.Join(errs, err)
+	telemetry.skippedResources, err = meter.Int64Counter(
+		"processor_dynatrace_skipped_resources",
+		metric.WithDescription("Number of resources left untouched since they contained dt.entity.host already"),
+		metric.WithUnit("{resources}"))
+	errs = errors.Join(errs, err)
+	telemetry.noHostIDResources, err = meter.Int64Counter(
+		"processor_dynatrace_resources_without_host_id",
+		metric.WithDescription("Number of resources left without dt.entity.host since no host ID has been discovered"),
+		metric.WithUnit("{resources}"))
+	errs = errors.Join(errs, err)
+	telemetry.normalizedMetricNames, err = meter.Int64Counter(
+		"processor_dynatrace_normalized_metric_names",
+		metric.WithDescription("Number of metric names rewritten to comply with the Dynatrace metric ingestion rules"),
+		metric.WithUnit("{metrics}"))
+	errs = errors.Join(errs, err)
+	telemetry.normalizedDimensions, err = meter.Int64Counter(
+		"processor_dynatrace_normalized_dimensions",
+		metric.WithDescription("Number of data point attributes rewritten to comply with the Dynatrace metric ingestion rules"),
+		metric.WithUnit("{attributes}"))
+	errs = errors.Join(errs, err)
+	telemetry.invalidMetricNames, err = meter.Int64Counter(
+		"processor_dynatrace_invalid_metric_names",
+		metric.WithDescription("Number of metrics dropped since no valid metric key could be derived from their name"),
+		metric.WithUnit("{metrics}"))
+	errs = errors.Join(errs, err)
+	telemetry.incompatibleMetrics, err = meter.Int64Counter(
+		"processor_dynatrace_incompatible_metrics",
+		metric.WithDescription("Number of metrics dropped or converted since Dynatrace can't ingest them"),
+		metric.WithUnit("{metrics}"))
+	errs = errors.Join(errs, err)
+	telemetry.cardinalityLimited, err = meter.Int64Counter(
+		"processor_dynatrace_cardinality_limited_data_points",
+		metric.WithDescription("Number of data points dropped or collapsed since their metric exceeded its cardinality limit"),
+		metric.WithUnit("{datapoints}"))
+	errs = errors.Join(errs, err)
+	telemetry.truncatedLogRecords, err = meter.Int64Counter(
+		"processor_dynatrace_truncated_log_records",
+		metric.WithDescription("Number of log records truncated to comply with the Dynatrace log ingestion limits"),
+		metric.WithUnit("{records}"))
+	errs = errors.Join(errs, err)
+	return telemetry, errs
+}
+
+// recordEnrichment records the outcomes of the resources of a batch
+// of the given signal
+func (pt *processorTelemetry) recordEnrichment(ctx context.Context, signal string, counts enrichmentCounts) {
+	attrs := metric.WithAttributes(attribute.String("signal", signal))
+	if counts.enriched > 0 {
+		pt.enrichedResources.Add(ctx, counts.enriched, attrs)
+	}
+	if counts.skipped > 0 {
+		pt.skippedResources.Add(ctx, counts.skipped, attrs)
+	}
+	if counts.noHostID > 0 {
+		pt.noHostIDResources.Add(ctx, counts.noHostID, attrs)
+	}
+}
+
+// registerHostIDDiscovery registers a gauge reporting whether the host ID
+// returned by the given function has been discovered, and its source
+func (pt *processorTelemetry) registerHostIDDiscovery(current func() *enrichment) error {
+	gauge, err := pt.meter.Int64ObservableGauge(
+		"processor_dynatrace_host_id_discovered",
+		metric.WithDescription("Whether the host ID has been discovered (1) or not (0), by source"),
+		metric.WithUnit("1"))
+	if err != nil {
+		return err
+	}
+	pt.hostIDDiscovery, err = pt.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		e := current()
+		discovered := int64(0)
+		if len(e.hostID) > 0 {
+			discovered = 1
+		}
+		o.ObserveInt64(gauge, discovered, metric.WithAttributes(attribute.String("source", e.hostIDSource)))
+		return nil
+	}, gauge)
+	return err
+}
+
+// unregister stops reporting the gauges
+func (pt *processorTelemetry) unregister() error {
+	if pt.hostIDDiscovery == nil {
+		return nil
+	}
+	err := pt.hostIDDiscovery.Unregister()
+	pt.hostIDDiscovery = nil
+	return err
+}
@@ -0,0 +1,155 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/collector/processor/processortest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/Reinhard-Pilz-Dynatrace/dynatraceprocessor/testdata"
+)
+
+// testTelemetry collects the telemetry reported by a processor
+type testTelemetry struct {
+	reader *sdkmetric.ManualReader
+}
+
+func newTestTelemetry() (processor.Settings, *testTelemetry) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	set := processortest.NewNopSettings()
+	set.LeveledMeterProvider = func(_ configtelemetry.Level) metric.MeterProvider {
+		return provider
+	}
+	return set, &testTelemetry{reader: reader}
+}
+
+// sum returns the total of all data points of the counter with the given name
+func (tt *testTelemetry) sum(t *testing.T, name string) int64 {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, tt.reader.Collect(context.Background(), &rm))
+	var total int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			if data, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range data.DataPoints {
+					total += dp.Value
+				}
+			}
+		}
+	}
+	return total
+}
+
+// sumBy returns the totals of the counter with the given name
+// per value of the given attribute
+func (tt *testTelemetry) sumBy(t *testing.T, name string, key attribute.Key) map[string]int64 {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, tt.reader.Collect(context.Background(), &rm))
+	totals := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			if data, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range data.DataPoints {
+					value, _ := dp.Attributes.Value(key)
+					totals[value.AsString()] += dp.Value
+				}
+			}
+		}
+	}
+	return totals
+}
+
+// gauge returns the values of the gauge with the given name
+// per value of the given attribute
+func (tt *testTelemetry) gauge(t *testing.T, name string, key attribute.Key) map[string]int64 {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, tt.reader.Collect(context.Background(), &rm))
+	values := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			if data, ok := m.Data.(metricdata.Gauge[int64]); ok {
+				for _, dp := range data.DataPoints {
+					value, _ := dp.Attributes.Value(key)
+					values[value.AsString()] = dp.Value
+				}
+			}
+		}
+	}
+	return values
+}
+
+func TestEnrichmentTelemetry(t *testing.T) {
+	set, telemetry := newTestTelemetry()
+	cfg := createDefaultConfig().(*Config)
+	cfg.Metadata = true
+	ctx := context.WithValue(context.Background(), MetaDataKeyDTEntityHost, "HOST-0123456789ABCDEF")
+	proc, err := newDynatraceProcessor(ctx, set, cfg, nil)
+	require.NoError(t, err)
+
+	td := testdata.GenerateTracesOneSpanNoResource()
+	td.ResourceSpans().AppendEmpty().Resource().Attributes().PutStr(KeyEntityHost, "HOST-FEDCBA9876543210")
+	_, err = proc.processTraces(ctx, td)
+	require.NoError(t, err)
+	_, err = proc.processLogs(ctx, testdata.GenerateLogsOneLogRecordNoResource())
+	require.NoError(t, err)
+
+	attr := attribute.Key("signal")
+	assert.Equal(t, map[string]int64{signalTraces: 1, signalLogs: 1}, telemetry.sumBy(t, "processor_dynatrace_enriched_resources", attr))
+	assert.Equal(t, map[string]int64{signalTraces: 1}, telemetry.sumBy(t, "processor_dynatrace_skipped_resources", attr))
+	assert.Empty(t, telemetry.sumBy(t, "processor_dynatrace_resources_without_host_id", attr))
+	assert.Equal(t, map[string]int64{hostIDSourceContext: 1}, telemetry.gauge(t, "processor_dynatrace_host_id_discovered", "source"))
+
+	require.NoError(t, proc.shutdown(ctx))
+	assert.Empty(t, telemetry.gauge(t, "processor_dynatrace_host_id_discovered", "source"))
+}
+
+func TestEnrichmentTelemetryWithoutHostID(t *testing.T) {
+	set, telemetry := newTestTelemetry()
+	cfg := createDefaultConfig().(*Config)
+	cfg.Metadata = true
+	cfg.MetaDataFiles = []string{}
+	cfg.RuxitHostIDFiles = []string{}
+	proc, err := newDynatraceProcessor(context.Background(), set, cfg, nil)
+	require.NoError(t, err)
+
+	_, err = proc.processMetrics(context.Background(), testdata.GenerateMetricsOneMetricNoResource())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int64{signalMetrics: 1}, telemetry.sumBy(t, "processor_dynatrace_resources_without_host_id", "signal"))
+	assert.Equal(t, map[string]int64{hostIDSourceNone: 0}, telemetry.gauge(t, "processor_dynatrace_host_id_discovered", "source"))
+}
@@ -0,0 +1,158 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// KeyServiceName is the resource attribute Dynatrace
+// service detection derives the service name from
+const KeyServiceName = "service.name"
+
+// unknownServicePrefix is the prefix of the service name
+// OpenTelemetry SDKs use in case none is configured
+const unknownServicePrefix = "unknown_service"
+
+// semconvMapping maps a deprecated span attribute to its
+// replacement in the current semantic conventions
+type semconvMapping struct {
+	deprecated string
+	current    string
+	// kinds restricts the mapping to spans of the given kinds.
+	// Empty means spans of any kind.
+	kinds []ptrace.SpanKind
+}
+
+var (
+	clientKinds = []ptrace.SpanKind{ptrace.SpanKindClient, ptrace.SpanKindProducer}
+	serverKinds = []ptrace.SpanKind{ptrace.SpanKindServer, ptrace.SpanKindConsumer}
+)
+
+// semconvMappings lists the deprecated span attributes getting mapped,
+// in the order they are getting applied. If several deprecated attributes
+// map to the same current one, the first one present wins.
+var semconvMappings = []semconvMapping{
+	// HTTP
+	{deprecated: "http.method", current: "http.request.method"},
+	{deprecated: "http.status_code", current: "http.response.status_code"},
+	{deprecated: "http.url", current: "url.full"},
+	{deprecated: "http.scheme", current: "url.scheme"},
+	{deprecated: "http.user_agent", current: "user_agent.original"},
+	{deprecated: "http.client_ip", current: "client.address"},
+	{deprecated: "http.request_content_length", current: "http.request.body.size"},
+	{deprecated: "http.response_content_length", current: "http.response.body.size"},
+	// Network
+	{deprecated: "net.peer.name", current: "server.address", kinds: clientKinds},
+	{deprecated: "net.peer.port", current: "server.port", kinds: clientKinds},
+	{deprecated: "net.peer.name", current: "client.address", kinds: serverKinds},
+	{deprecated: "net.peer.port", current: "client.port", kinds: serverKinds},
+	{deprecated: "net.host.name", current: "server.address", kinds: serverKinds},
+	{deprecated: "net.host.port", current: "server.port", kinds: serverKinds},
+	{deprecated: "net.sock.peer.addr", current: "network.peer.address"},
+	{deprecated: "net.sock.peer.port", current: "network.peer.port"},
+	{deprecated: "net.protocol.name", current: "network.protocol.name"},
+	{deprecated: "net.protocol.version", current: "network.protocol.version"},
+	// Database
+	{deprecated: "db.name", current: "db.namespace"},
+	{deprecated: "db.statement", current: "db.query.text"},
+	{deprecated: "db.operation", current: "db.operation.name"},
+	// Messaging
+	{deprecated: "messaging.destination", current: "messaging.destination.name"},
+}
+
+// appliesTo checks whether the mapping applies to spans of the given kind
+func (m semconvMapping) appliesTo(kind ptrace.SpanKind) bool {
+	if len(m.kinds) == 0 {
+		return true
+	}
+	for _, k := range m.kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// spanAttributes holds the settings of the span attribute pass
+type spanAttributes struct {
+	defaultServiceName string
+	keepDeprecated     bool
+}
+
+// apply adds the default service name to the resources of the given
+// traces lacking one and maps deprecated span attributes
+func (sa spanAttributes) apply(td ptrace.Traces) {
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		if sa.defaultServiceName != "" {
+			applyDefaultServiceName(rs.Resource().Attributes(), sa.defaultServiceName)
+		}
+		sss := rs.ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			spans := sss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				sa.mapDeprecated(span.Kind(), span.Attributes())
+			}
+		}
+	}
+}
+
+// applyDefaultServiceName sets `service.name` if it's missing, empty
+// or has been defaulted by an OpenTelemetry SDK to `unknown_service`
+func applyDefaultServiceName(attrs pcommon.Map, serviceName string) {
+	if value, found := attrs.Get(KeyServiceName); found {
+		if s := value.AsString(); s != "" && !strings.HasPrefix(s, unknownServicePrefix) {
+			return
+		}
+	}
+	attrs.PutStr(KeyServiceName, serviceName)
+}
+
+// mapDeprecated adds the current attribute for every deprecated attribute
+// present, unless the current attribute is present already.
+// Unless configured otherwise, the deprecated attributes are getting removed.
+func (sa spanAttributes) mapDeprecated(kind ptrace.SpanKind, attrs pcommon.Map) {
+	var mapped []string
+	for _, m := range semconvMappings {
+		if !m.appliesTo(kind) {
+			continue
+		}
+		if _, found := attrs.Get(m.deprecated); !found {
+			continue
+		}
+		if _, found := attrs.Get(m.current); !found {
+			// adding the attribute might move the existing ones
+			current := attrs.PutEmpty(m.current)
+			value, _ := attrs.Get(m.deprecated)
+			value.CopyTo(current)
+		}
+		mapped = append(mapped, m.deprecated)
+	}
+	if sa.keepDeprecated {
+		return
+	}
+	for _, key := range mapped {
+		attrs.Remove(key)
+	}
+}
@@ -0,0 +1,141 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestMapDeprecatedSpanAttributes(t *testing.T) {
+	tests := []struct {
+		name           string
+		kind           ptrace.SpanKind
+		keepDeprecated bool
+		attributes     map[string]any
+		expected       map[string]any
+	}{
+		{
+			name:       "http_client",
+			kind:       ptrace.SpanKindClient,
+			attributes: map[string]any{"http.url": "https://example.com/cart", "http.status_code": int64(200), "net.peer.name": "example.com"},
+			expected:   map[string]any{"url.full": "https://example.com/cart", "http.response.status_code": int64(200), "server.address": "example.com"},
+		},
+		{
+			name:       "http_server",
+			kind:       ptrace.SpanKindServer,
+			attributes: map[string]any{"net.host.name": "shop.local", "net.peer.name": "10.0.0.1", "http.route": "/cart"},
+			expected:   map[string]any{"server.address": "shop.local", "client.address": "10.0.0.1", "http.route": "/cart"},
+		},
+		{
+			name:       "current_attribute_present",
+			kind:       ptrace.SpanKindClient,
+			attributes: map[string]any{"db.name": "legacy", "db.namespace": "orders", "db.system": "postgresql"},
+			expected:   map[string]any{"db.namespace": "orders", "db.system": "postgresql"},
+		},
+		{
+			name:           "keep_deprecated",
+			kind:           ptrace.SpanKindInternal,
+			keepDeprecated: true,
+			attributes:     map[string]any{"db.statement": "SELECT 1", "net.peer.name": "db.local"},
+			expected:       map[string]any{"db.statement": "SELECT 1", "db.query.text": "SELECT 1", "net.peer.name": "db.local"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			span := ptrace.NewSpan()
+			span.SetKind(tt.kind)
+			require.NoError(t, span.Attributes().FromRaw(tt.attributes))
+
+			spanAttributes{keepDeprecated: tt.keepDeprecated}.mapDeprecated(span.Kind(), span.Attributes())
+
+			assert.Equal(t, tt.expected, span.Attributes().AsRaw())
+		})
+	}
+}
+
+func TestSemconvMappingsUnique(t *testing.T) {
+	// the same deprecated attribute must not map to different
+	// current attributes for the same span kind
+	kinds := []ptrace.SpanKind{
+		ptrace.SpanKindUnspecified, ptrace.SpanKindInternal, ptrace.SpanKindServer,
+		ptrace.SpanKindClient, ptrace.SpanKindProducer, ptrace.SpanKindConsumer,
+	}
+	for _, kind := range kinds {
+		seen := map[string]string{}
+		for _, m := range semconvMappings {
+			if !m.appliesTo(kind) {
+				continue
+			}
+			if current, found := seen[m.deprecated]; found {
+				t.Errorf("%s maps to %s and %s for %s spans", m.deprecated, current, m.current, kind)
+			}
+			seen[m.deprecated] = m.current
+		}
+	}
+}
+
+func TestDefaultServiceName(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes map[string]any
+		expected   string
+	}{
+		{name: "missing", attributes: map[string]any{}, expected: "checkout"},
+		{name: "empty", attributes: map[string]any{KeyServiceName: ""}, expected: "checkout"},
+		{name: "sdk_default", attributes: map[string]any{KeyServiceName: "unknown_service:java"}, expected: "checkout"},
+		{name: "present", attributes: map[string]any{KeyServiceName: "cart"}, expected: "cart"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := ptrace.NewTraces()
+			rs := td.ResourceSpans().AppendEmpty()
+			require.NoError(t, rs.Resource().Attributes().FromRaw(tt.attributes))
+
+			spanAttributes{defaultServiceName: "checkout"}.apply(td)
+
+			serviceName, found := rs.Resource().Attributes().Get(KeyServiceName)
+			require.True(t, found)
+			assert.Equal(t, tt.expected, serviceName.Str())
+		})
+	}
+}
+
+func TestProcessTracesSpanAttributes(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetaDataFiles = []string{}
+	cfg.RuxitHostIDFiles = []string{}
+	cfg.SpanAttributes = SpanAttributesConfig{Enabled: true, DefaultServiceName: "checkout"}
+	set, _ := newTestTelemetry()
+	proc, err := newDynatraceProcessor(context.Background(), set, cfg, nil)
+	require.NoError(t, err)
+
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("http.method", "GET")
+	_, err = proc.processTraces(context.Background(), td)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{"http.request.method": "GET"}, span.Attributes().AsRaw())
+	assert.Equal(t, map[string]any{KeyServiceName: "checkout"}, rs.Resource().Attributes().AsRaw())
+}
@@ -0,0 +1,152 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// fileWatch tracks the enrichment files being watched
+// and the directories containing them
+type fileWatch struct {
+	watcher *fsnotify.Watcher
+	// filePaths holds the absolute paths of the files to react on
+	filePaths map[string]bool
+	// dirs holds the directories already added to the watcher,
+	// along with whether adding them succeeded
+	dirs map[string]bool
+}
+
+// add watches the directory containing the given file and returns
+// false if that directory couldn't be watched
+func (w *fileWatch) add(filePath string) bool {
+	absFilePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return false
+	}
+	w.filePaths[absFilePath] = true
+	dir := filepath.Dir(absFilePath)
+	if watched, found := w.dirs[dir]; found {
+		return watched
+	}
+	w.dirs[dir] = w.watcher.Add(dir) == nil
+	return w.dirs[dir]
+}
+
+// startWatching watches the directories containing the enrichment files,
+// as well as the directories containing the files the "magic" files point at.
+// Watching the directories instead of the files themselves ensures
+// that files getting deleted and re-created are still being noticed.
+// The directories which couldn't be watched, e.g. because they don't exist
+// yet, are getting returned, so that the caller can poll them instead.
+// An error is getting returned if not a single directory could be watched,
+// or if the files are getting read from a file system other than the one
+// of the current host.
+func (en *enrichmentEngine) startWatching(done <-chan struct{}) ([]string, error) {
+	if en.files.fsys != nil {
+		return nil, errors.New("enrichment files read from a custom file system can't be watched")
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &fileWatch{watcher: watcher, filePaths: map[string]bool{}, dirs: map[string]bool{}}
+	for _, filePath := range en.files.all() {
+		w.add(filePath)
+	}
+	en.watchTargets(w)
+
+	var unwatched []string
+	for dir, watched := range w.dirs {
+		if !watched {
+			en.logger.Debug("Unable to watch directory", zap.String("dir", dir))
+			unwatched = append(unwatched, dir)
+		}
+	}
+	sort.Strings(unwatched)
+	if len(unwatched) == len(w.dirs) {
+		watcher.Close()
+		return unwatched, errors.New("none of the directories containing enrichment files could be watched")
+	}
+
+	en.wg.Add(1)
+	go en.watchLoop(w, done)
+	return unwatched, nil
+}
+
+// watchTargets watches the files the "magic" metadata files currently
+// point at, so that changes to these files are getting noticed as well
+func (en *enrichmentEngine) watchTargets(w *fileWatch) {
+	for _, filePath := range en.files.metaData {
+		content, err := readFile(nil, filePath)
+		if err != nil {
+			continue
+		}
+		if target, ok := enrichmentFileTarget(content); ok && !w.add(target) {
+			en.logger.Debug("Unable to watch directory", zap.String("dir", filepath.Dir(target)))
+		}
+	}
+}
+
+// watchLoop re-evaluates the enrichment files once no further changes
+// to them have been noticed for the configured debounce duration
+func (en *enrichmentEngine) watchLoop(w *fileWatch, done <-chan struct{}) {
+	defer en.wg.Done()
+	defer w.watcher.Close()
+
+	debounce := time.NewTimer(en.watch.Debounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if !w.filePaths[filepath.Clean(event.Name)] {
+				continue
+			}
+			if !debounce.Stop() {
+				select {
+				case <-debounce.C:
+				default:
+				}
+			}
+			debounce.Reset(en.watch.Debounce)
+		case <-debounce.C:
+			// a "magic" file may point at another file by now
+			en.watchTargets(w)
+			en.refresh()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			en.logger.Warn("Error while watching enrichment files", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/**
+ * @license
+ * Copyright 2020 Dynatrace LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynatraceprocessor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func newWatchTestContext(ruxitHostIDFilePath string) context.Context {
+	ctx := context.WithValue(context.Background(), CtxKeyMetaDataPropertiesFilePaths, []string{})
+	return context.WithValue(ctx, CtxKeyRuxitHostIDFilePaths, []string{ruxitHostIDFilePath})
+}
+
+func TestWatchEnrichmentFiles(t *testing.T) {
+	ruxitHostIDFilePath := filepath.Join(t.TempDir(), "ruxithost.id")
+	ctx := newWatchTestContext(ruxitHostIDFilePath)
+
+	cfg := &Config{Metadata: true, Watch: WatchConfig{Enabled: true, Debounce: 20 * time.Millisecond}}
+	proc, err := newDynatraceProcessor(ctx, processortest.NewNopSettings(), cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, proc.start(ctx, componenttest.NewNopHost()))
+	defer func() {
+		assert.NoError(t, proc.shutdown(ctx))
+	}()
+
+	hostID := func() string { return proc.engine.current.Load().hostID }
+
+	// rapid writes are getting debounced, only the final value matters
+	for _, content := range []string{"0000000000000001", "0000000000000002", "AAF98EFF909EE3F6"} {
+		require.NoError(t, os.WriteFile(ruxitHostIDFilePath, []byte(content), 0o600))
+	}
+	assert.Eventually(t, func() bool { return hostID() == "HOST-AAF98EFF909EE3F6" }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.Remove(ruxitHostIDFilePath))
+	assert.Eventually(t, func() bool { return hostID() == "" }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(ruxitHostIDFilePath, []byte("BBF98EFF909EE3F6"), 0o600))
+	assert.Eventually(t, func() bool { return hostID() == "HOST-BBF98EFF909EE3F6" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWatchFallsBackToPolling(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "enrichment")
+	ruxitHostIDFilePath := filepath.Join(dir, "ruxithost.id")
+	ctx := newWatchTestContext(ruxitHostIDFilePath)
+
+	fallbackInterval := defaultWatchFallbackInterval
+	defaultWatchFallbackInterval = 10 * time.Millisecond
+	defer func() { defaultWatchFallbackInterval = fallbackInterval }()
+
+	// the directory doesn't exist yet, hence it can't be watched
+	cfg := &Config{Metadata: true, Watch: WatchConfig{Enabled: true, Debounce: 20 * time.Millisecond}}
+	proc, err := newDynatraceProcessor(ctx, processortest.NewNopSettings(), cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, proc.start(ctx, componenttest.NewNopHost()))
+	defer func() {
+		assert.NoError(t, proc.shutdown(ctx))
+	}()
+
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	require.NoError(t, os.WriteFile(ruxitHostIDFilePath, []byte("AAF98EFF909EE3F6"), 0o600))
+	assert.Eventually(t, func() bool { return proc.engine.current.Load().hostID == "HOST-AAF98EFF909EE3F6" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWatchPollsDirectoriesWhichCantBeWatched(t *testing.T) {
+	watchedDir := t.TempDir()
+	missingDir := filepath.Join(t.TempDir(), "enrichment")
+	ruxitHostIDFilePath := filepath.Join(missingDir, "ruxithost.id")
+	ctx := context.WithValue(newWatchTestContext(ruxitHostIDFilePath), CtxKeyRuxitHostIDFilePaths, []string{
+		ruxitHostIDFilePath,
+		filepath.Join(watchedDir, "ruxithost.id"),
+	})
+
+	fallbackInterval := defaultWatchFallbackInterval
+	defaultWatchFallbackInterval = 10 * time.Millisecond
+	defer func() { defaultWatchFallbackInterval = fallbackInterval }()
+
+	// one directory can be watched, the other one doesn't exist yet
+	cfg := &Config{Metadata: true, Watch: WatchConfig{Enabled: true, Debounce: 20 * time.Millisecond}}
+	proc, err := newDynatraceProcessor(ctx, processortest.NewNopSettings(), cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, proc.start(ctx, componenttest.NewNopHost()))
+	defer func() {
+		assert.NoError(t, proc.shutdown(ctx))
+	}()
+
+	require.NoError(t, os.MkdirAll(missingDir, 0o700))
+	require.NoError(t, os.WriteFile(ruxitHostIDFilePath, []byte("AAF98EFF909EE3F6"), 0o600))
+	assert.Eventually(t, func() bool { return proc.engine.current.Load().hostID == "HOST-AAF98EFF909EE3F6" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWatchMagicFileTarget(t *testing.T) {
+	magicFilePath := filepath.Join(t.TempDir(), "dt_metadata.properties")
+	targetFilePath := filepath.Join(t.TempDir(), "dt_metadata.properties")
+	require.NoError(t, os.WriteFile(magicFilePath, []byte(targetFilePath), 0o600))
+	require.NoError(t, os.WriteFile(targetFilePath, []byte("dt.entity.host=HOST-AAF98EFF909EE3F6"), 0o600))
+	ctx := context.WithValue(context.Background(), CtxKeyMetaDataPropertiesFilePaths, []string{magicFilePath})
+	ctx = context.WithValue(ctx, CtxKeyRuxitHostIDFilePaths, []string{})
+
+	cfg := &Config{Metadata: true, Watch: WatchConfig{Enabled: true, Debounce: 20 * time.Millisecond}}
+	proc, err := newDynatraceProcessor(ctx, processortest.NewNopSettings(), cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, proc.start(ctx, componenttest.NewNopHost()))
+	defer func() {
+		assert.NoError(t, proc.shutdown(ctx))
+	}()
+
+	hostID := func() string { return proc.engine.current.Load().hostID }
+	require.Equal(t, "HOST-AAF98EFF909EE3F6", hostID())
+
+	require.NoError(t, os.WriteFile(targetFilePath, []byte("dt.entity.host=HOST-BBF98EFF909EE3F6"), 0o600))
+	assert.Eventually(t, func() bool { return hostID() == "HOST-BBF98EFF909EE3F6" }, 5*time.Second, 10*time.Millisecond)
+}